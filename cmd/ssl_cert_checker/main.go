@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log/slog" // Ensure you use slog for structured logging
+	"net"
 	"os"
 	"sync"
 	"time"
@@ -32,15 +33,25 @@ func main() {
 	slog.Info("configuration loaded", "mode", cfg.ConfigType, "timeout", cfg.Timeout)
 
 	// 3. Load Targets
-	targets, err := loadTargets(cfg)
+	scope, err := config.NewScope(cfg)
 	if err != nil {
+		slog.Error("invalid scope", "error", err)
+		os.Exit(1)
+	}
+	targets, err := loadTargets(cfg, scope)
+	if err != nil {
+		reportExclusions(cfg, scope.Excluded)
 		slog.Error("failed to load targets", "error", err)
 		os.Exit(1)
 	}
-	slog.Info("targets loaded", "domains", len(targets.Domains), "ports", len(targets.Ports))
+	slog.Info("targets loaded", "domains", len(targets.Domains), "targets", len(targets.Targets), "cidrs", len(targets.Cidr), "ports", len(targets.Ports))
+
+	// 4. Run Scan (discovery may already have produced rows of its own)
+	results := append(runScan(cfg, targets, scope), targets.Results...)
+	reportExclusions(cfg, scope.Excluded)
 
-	// 4. Run Scan
-	results := runScan(cfg, targets)
+	// Issued certificates are compared with what the scan actually saw served
+	discovery.MarkUnservedVaultCerts(results, cfg.AlertDays)
 
 	// 5. Send Alerts (Refactored)
 	processAlerts(cfg, results)
@@ -59,7 +70,7 @@ func setupLogging(verbose bool) {
 	slog.SetDefault(logger)
 }
 
-func loadTargets(cfg *config.AppConfig) (config.Config, error) {
+func loadTargets(cfg *config.AppConfig, scope *config.Scope) (config.Config, error) {
 	var targetConf config.Config
 
 	cliPorts, err := config.ParsePorts(cfg.PortString)
@@ -78,61 +89,101 @@ func loadTargets(cfg *config.AppConfig) (config.Config, error) {
 		return targetConf, fmt.Errorf("failed to fetch targets: %w", err)
 	}
 
-	// Expand CIDRs
-	for _, cidr := range targetConf.Cidr {
-		slog.Debug("expanding cidr", "cidr", cidr)
-		ips, err := config.ConvertCidrToIPList(cidr)
-		if err != nil {
-			return targetConf, fmt.Errorf("cidr error: %w", err)
-		}
-		targetConf.Domains = append(targetConf.Domains, ips...)
+	// Validate CIDRs; their addresses are only expanded while scanning
+	cidrSize, err := targetConf.CIDRSize(cfg.CIDROptions())
+	if err != nil {
+		return targetConf, fmt.Errorf("cidr error: %w", err)
+	}
+
+	// Drop out-of-scope targets before anything is dialled. CIDR addresses are
+	// filtered as they are expanded, so the cap counts whole ranges.
+	scope.Apply(&targetConf)
+	if err := scope.CheckCap(uint64(len(targetConf.Domains)+len(targetConf.Targets)) + cidrSize); err != nil {
+		return targetConf, err
 	}
 
-	// Merge Ports
+	// Merge Ports: this is the default list, targets discovered with their own
+	// ports (e.g. load balancer listeners) keep them
 	targetConf.Ports = config.MergePorts(targetConf.Ports, cliPorts)
 	if len(targetConf.Ports) == 0 {
 		targetConf.Ports = config.DefaultPorts
 	}
 
-	if len(targetConf.Domains) == 0 {
+	if len(targetConf.Domains) == 0 && len(targetConf.Targets) == 0 && len(targetConf.Cidr) == 0 && len(targetConf.Results) == 0 {
 		return targetConf, fmt.Errorf("no domains found to test")
 	}
 
 	return targetConf, nil
 }
 
-func runScan(cfg *config.AppConfig, targets config.Config) []config.DomainValidity {
+func reportExclusions(cfg *config.AppConfig, excluded []config.Exclusion) {
+	reasons := make(map[string]int)
+	total := 0
+	for _, e := range excluded {
+		slog.Debug("target excluded", "host", e.Host, "address", e.Address, "reason", e.Reason, "rule", e.Rule, "count", e.Targets())
+		reasons[e.Reason] += e.Targets()
+		total += e.Targets()
+	}
+	if total > 0 {
+		slog.Info("targets excluded", "total", total, "denylist", reasons["denylist"], "exclude", reasons["exclude"], "not_included", reasons["not-included"])
+	}
+
+	if cfg.ExclusionReport == "" {
+		return
+	}
+	if err := config.WriteExclusionReport(cfg.ExclusionReport, excluded); err != nil {
+		slog.Error("failed to write exclusion report", "error", err)
+		return
+	}
+	slog.Info("exclusion report saved", "path", cfg.ExclusionReport)
+}
+
+func runScan(cfg *config.AppConfig, targets config.Config, scope *config.Scope) []config.DomainValidity {
 	start := time.Now()
-	totalWork := len(targets.Domains) * len(targets.Ports)
-	resultsChan := make(chan config.DomainValidity, totalWork)
+	resultsChan := make(chan config.DomainValidity, cfg.Split)
 	var wg sync.WaitGroup
 	ctx := context.Background()
 
-	for i := 0; i < len(targets.Domains); i += cfg.Split {
-		end := i + cfg.Split
-		if end > len(targets.Domains) {
-			end = len(targets.Domains)
+	// Collect while scanning so the channel never has to hold every result
+	var results []config.DomainValidity
+	collected := make(chan struct{})
+	go func() {
+		for result := range resultsChan {
+			results = append(results, result)
 		}
-
+		close(collected)
+	}()
+
+	// Targets are batched as they are produced, so a large CIDR range is never
+	// held in memory; at most -workers batches run at once
+	workers := make(chan struct{}, max(cfg.Workers, 1))
+	launch := func(batch []config.Target) {
+		workers <- struct{}{}
 		wg.Add(1)
-		go scan.ProcessDomains(
-			ctx,
-			targets.Domains[i:end],
-			targets.Ports,
-			cfg.Timeout,
-			start,
-			resultsChan,
-			&wg,
-		)
+		go func() {
+			defer func() { <-workers }()
+			scan.ProcessTargets(ctx, batch, targets.Ports, cfg.Timeout, start, resultsChan, &wg)
+		}()
+	}
+
+	batch := make([]config.Target, 0, cfg.Split)
+	for target := range scope.Filter(targets.Each(cfg.CIDROptions())) {
+		if cfg.DiscoverSNI && target.SNI == "" && net.ParseIP(target.Host) != nil {
+			target.DiscoverSNI = true
+		}
+		batch = append(batch, target)
+		if len(batch) >= cfg.Split {
+			launch(batch)
+			batch = make([]config.Target, 0, cfg.Split)
+		}
+	}
+	if len(batch) > 0 {
+		launch(batch)
 	}
 
 	wg.Wait()
 	close(resultsChan)
-
-	var results []config.DomainValidity
-	for result := range resultsChan {
-		results = append(results, result)
-	}
+	<-collected
 
 	slog.Info("scan completed", "duration", time.Since(start).String(), "results", len(results))
 	return results
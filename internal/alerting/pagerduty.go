@@ -59,7 +59,7 @@ func SendAlert(integrationKey string, alertDays int, data []config.DomainValidit
 
 	for _, r := range data {
 
-		if alertDays < r.DaysUntilExpiry {
+		if r.AlertThreshold(alertDays) < r.DaysUntilExpiry {
 			continue
 		}
 		eventPayload := PagerDutyEventPayload{
@@ -73,6 +73,7 @@ func SendAlert(integrationKey string, alertDays int, data []config.DomainValidit
 				"NotAfter":        fmt.Sprint(r.NotAfter),
 				"DaysUntilExpiry": fmt.Sprint(r.DaysUntilExpiry),
 				"CommonName":      r.CommonName,
+				"Owner":           r.Owner,
 			},
 		}
 
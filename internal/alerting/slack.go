@@ -30,7 +30,7 @@ func SendSlackAlert(webhookURL string, alertDays int, data []config.DomainValidi
 	var expiring []config.DomainValidity
 	for _, r := range data {
 		// If error exists or days until expiry is less than threshold
-		if r.Error != "" || r.DaysUntilExpiry <= alertDays {
+		if r.Error != "" || r.DaysUntilExpiry <= r.AlertThreshold(alertDays) {
 			expiring = append(expiring, r)
 		}
 	}
@@ -56,10 +56,15 @@ func SendSlackAlert(webhookURL string, alertDays int, data []config.DomainValidi
 			color = "danger"
 		}
 
+		text := fmt.Sprintf("Common Name: %s\nStatus: %s\nIP: %s", r.CommonName, status, r.IPAddress)
+		if r.Owner != "" {
+			text += fmt.Sprintf("\nOwner: %s", r.Owner)
+		}
+
 		attachments = append(attachments, Attachment{
 			Color:  color,
 			Title:  fmt.Sprintf("%s (Port: %d)", r.Domain, r.Port),
-			Text:   fmt.Sprintf("Common Name: %s\nStatus: %s\nIP: %s", r.CommonName, status, r.IPAddress),
+			Text:   text,
 			Footer: "SSL Cert Checker",
 		})
 	}
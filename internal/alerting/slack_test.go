@@ -107,3 +107,24 @@ func TestSendSlackAlert_NoWebhook(t *testing.T) {
 	err := SendSlackAlert("", 5, nil)
 	assert.NoError(t, err)
 }
+
+func TestSendSlackAlert_PerTargetThreshold(t *testing.T) {
+	var receivedPayload SlackMessage
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&receivedPayload)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	testData := []config.DomainValidity{
+		{Domain: "payments.com", Port: 443, DaysUntilExpiry: 20, AlertDays: 30, Owner: "team-payments"}, // Within its own threshold
+		{Domain: "blog.com", Port: 443, DaysUntilExpiry: 20},                                            // Outside the global one
+	}
+
+	err := SendSlackAlert(ts.URL, 5, testData)
+
+	assert.NoError(t, err)
+	assert.Len(t, receivedPayload.Attachments, 1)
+	assert.Contains(t, receivedPayload.Attachments[0].Title, "payments.com")
+	assert.Contains(t, receivedPayload.Attachments[0].Text, "Owner: team-payments")
+}
@@ -37,7 +37,7 @@ func SendTeamsAlert(webhookURL string, alertDays int, data []config.DomainValidi
 
 	var expiring []config.DomainValidity
 	for _, r := range data {
-		if r.Error != "" || r.DaysUntilExpiry <= alertDays {
+		if r.Error != "" || r.DaysUntilExpiry <= r.AlertThreshold(alertDays) {
 			expiring = append(expiring, r)
 		}
 	}
@@ -58,16 +58,21 @@ func SendTeamsAlert(webhookURL string, alertDays int, data []config.DomainValidi
 			status = fmt.Sprintf("Error: %s", r.Error)
 		}
 
+		facts := []TeamsFact{
+			{Name: "Common Name", Value: r.CommonName},
+			{Name: "IP Address", Value: r.IPAddress},
+			{Name: "Not After", Value: r.NotAfter.Format("2006-01-02")},
+			{Name: "Chain Status", Value: r.ChainStatus},
+		}
+		if r.Owner != "" {
+			facts = append(facts, TeamsFact{Name: "Owner", Value: r.Owner})
+		}
+
 		sections = append(sections, TeamsSection{
 			ActivityTitle:    title,
 			ActivitySubtitle: status,
 			Markdown:         true,
-			Facts: []TeamsFact{
-				{Name: "Common Name", Value: r.CommonName},
-				{Name: "IP Address", Value: r.IPAddress},
-				{Name: "Not After", Value: r.NotAfter.Format("2006-01-02")},
-				{Name: "Chain Status", Value: r.ChainStatus},
-			},
+			Facts:            facts,
 		})
 	}
 
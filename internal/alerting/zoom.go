@@ -39,7 +39,7 @@ type zoomBody struct {
 func (z *ZoomAlert) Send(results []config.DomainValidity, alertDays int) error {
 	var expired []config.DomainValidity
 	for _, r := range results {
-		if r.DaysUntilExpiry <= alertDays {
+		if r.DaysUntilExpiry <= r.AlertThreshold(alertDays) {
 			expired = append(expired, r)
 		}
 	}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"fmt"
+	"iter"
+	"math"
+	"net/netip"
+)
+
+// CIDROptions bounds how CIDR ranges are expanded into scan targets
+type CIDROptions struct {
+	SkipNetworkBroadcast bool   // Skip the first and last address of IPv4 ranges larger than /31
+	MaxSize              uint64 // Refuse ranges yielding more addresses than this; 0 means no limit
+	AllowIPv6            bool
+}
+
+// ParseCIDR validates a range against opts and returns its network prefix and
+// the number of addresses expanding it yields
+func ParseCIDR(s string, opts CIDROptions) (netip.Prefix, uint64, error) {
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, 0, fmt.Errorf("failed to parse CIDR: %w", err)
+	}
+	prefix = prefix.Masked()
+
+	if prefix.Addr().Is6() && !opts.AllowIPv6 {
+		return netip.Prefix{}, 0, fmt.Errorf("IPv6 range %s requires -cidripv6", s)
+	}
+
+	size := uint64(math.MaxUint64)
+	if hostBits := prefix.Addr().BitLen() - prefix.Bits(); hostBits < 64 {
+		size = 1 << hostBits
+	}
+	if skipsEnds(prefix, opts) {
+		size -= 2
+	}
+
+	if opts.MaxSize > 0 && size > opts.MaxSize {
+		return netip.Prefix{}, 0, fmt.Errorf("CIDR %s has %d addresses, more than the limit of %d", s, size, opts.MaxSize)
+	}
+	return prefix, size, nil
+}
+
+// ExpandCIDR returns the addresses of a range one at a time, without
+// materializing them, along with how many there are
+func ExpandCIDR(s string, opts CIDROptions) (iter.Seq[string], uint64, error) {
+	prefix, size, err := ParseCIDR(s, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	seq := func(yield func(string) bool) {
+		addr := prefix.Addr()
+		if skipsEnds(prefix, opts) {
+			addr = addr.Next()
+		}
+		for i := uint64(0); i < size && addr.IsValid(); i++ {
+			if !yield(addr.String()) {
+				return
+			}
+			addr = addr.Next()
+		}
+	}
+	return seq, size, nil
+}
+
+// skipsEnds reports whether the network and broadcast addresses are dropped;
+// /31 and /32 have neither (RFC 3021)
+func skipsEnds(prefix netip.Prefix, opts CIDROptions) bool {
+	return opts.SkipNetworkBroadcast && prefix.Addr().Is4() && prefix.Bits() <= 30
+}
+
+// CIDRSize validates every range of the config and returns the number of
+// addresses they expand to
+func (c Config) CIDRSize(opts CIDROptions) (uint64, error) {
+	var total uint64
+	for _, cidr := range c.Cidr {
+		_, size, err := ParseCIDR(cidr, opts)
+		if err != nil {
+			return 0, err
+		}
+		if total += size; total < size {
+			total = math.MaxUint64
+		}
+	}
+	return total, nil
+}
+
+// Each yields every target of the config: the domains, the tagged targets and
+// then the CIDR addresses, expanded lazily. Ranges are expected to have been
+// validated with CIDRSize; invalid ones are skipped.
+func (c Config) Each(opts CIDROptions) iter.Seq[Target] {
+	return func(yield func(Target) bool) {
+		for _, t := range c.AllTargets() {
+			if !yield(t) {
+				return
+			}
+		}
+		for _, cidr := range c.Cidr {
+			ips, _, err := ExpandCIDR(cidr, opts)
+			if err != nil {
+				continue
+			}
+			for ip := range ips {
+				if !yield(Target{Host: ip}) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// CIDROptions returns the CIDR expansion settings of the run
+func (c *AppConfig) CIDROptions() CIDROptions {
+	return CIDROptions{
+		SkipNetworkBroadcast: c.CIDRSkipNetwork,
+		MaxSize:              uint64(max(c.CIDRMaxSize, 0)),
+		AllowIPv6:            c.CIDRAllowIPv6,
+	}
+}
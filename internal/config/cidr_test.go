@@ -0,0 +1,70 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCIDR(t *testing.T) {
+	tests := []struct {
+		name    string
+		cidr    string
+		opts    CIDROptions
+		want    uint64
+		wantErr string
+	}{
+		{name: "Whole /24", cidr: "10.0.0.0/24", want: 256},
+		{name: "Network and broadcast skipped", cidr: "10.0.0.7/24", opts: CIDROptions{SkipNetworkBroadcast: true}, want: 254},
+		{name: "/31 has no broadcast", cidr: "10.0.0.0/31", opts: CIDROptions{SkipNetworkBroadcast: true}, want: 2},
+		{name: "Size cap", cidr: "10.0.0.0/12", opts: CIDROptions{MaxSize: 65536}, wantErr: "more than the limit of 65536"},
+		{name: "IPv6 needs opt-in", cidr: "2001:db8::/120", wantErr: "requires -cidripv6"},
+		{name: "IPv6 allowed", cidr: "2001:db8::/120", opts: CIDROptions{AllowIPv6: true}, want: 256},
+		{name: "Garbage", cidr: "10.0.0.0/33", wantErr: "failed to parse CIDR"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, got, err := ParseCIDR(tt.cidr, tt.opts)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestExpandCIDR(t *testing.T) {
+	ips, size, err := ExpandCIDR("192.168.1.0/30", CIDROptions{SkipNetworkBroadcast: true})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), size)
+
+	var got []string
+	for ip := range ips {
+		got = append(got, ip)
+	}
+	assert.Equal(t, []string{"192.168.1.1", "192.168.1.2"}, got)
+}
+
+func TestConfigEach(t *testing.T) {
+	conf := Config{
+		Domains: []string{"example.com"},
+		Targets: []Target{{Host: "mail.example.com", Protocol: "smtp"}},
+		Cidr:    []string{"10.0.0.0/31"},
+	}
+
+	size, err := conf.CIDRSize(CIDROptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), size)
+
+	var hosts []string
+	for target := range conf.Each(CIDROptions{}) {
+		hosts = append(hosts, target.Host)
+	}
+	assert.Equal(t, []string{"example.com", "mail.example.com", "10.0.0.0", "10.0.0.1"}, hosts)
+
+	_, err = Config{Cidr: []string{"10.0.0.0/8"}}.CIDRSize(CIDROptions{MaxSize: 256})
+	assert.Error(t, err)
+}
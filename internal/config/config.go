@@ -5,7 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"net/netip"
+	"path"
+	"strings"
+
+	"sigs.k8s.io/yaml"
 )
 
 // Export DefaultPorts so main can use it
@@ -19,25 +23,28 @@ func LoadConfig(filePath string) (Config, error) {
 		return Config{}, fmt.Errorf("failed to read config file: %v", err)
 	}
 
-	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
-		return Config{}, fmt.Errorf("failed to parse config file: %v", err)
+	return ParseConfig(data, filePath)
+}
+
+// ParseConfig decodes and validates a target configuration, wherever it was
+// fetched from. name (a path or URL) selects YAML or JSON by its extension.
+func ParseConfig(data []byte, name string) (Config, error) {
+	config, err := DecodeConfig(data, name)
+	if err != nil {
+		return Config{}, err
 	}
 
-	if len(config.Domains) == 0 {
+	if len(config.Domains) == 0 && len(config.Targets) == 0 {
 		if len(config.Cidr) == 0 {
-			return Config{}, errors.New("invalid config: missing required domains or cidr")
+			return Config{}, errors.New("invalid config: missing required domains, targets or cidr")
 		}
 	}
 
-	var ips []string
+	// Ranges are only validated here; they are expanded lazily when scanning
 	for _, r := range config.Cidr {
-		// Ensure ConvertCidrToIPList is defined in helpers.go or utils.go
-		ips, err = ConvertCidrToIPList(r) 
-		if err != nil {
-			log.Fatalf("Error loading cidr configuration: %v", err)
+		if _, err := netip.ParsePrefix(r); err != nil {
+			return Config{}, fmt.Errorf("invalid cidr %q in config: %v", r, err)
 		}
-		config.Domains = append(config.Domains, ips...)
 	}
 
 	if len(config.Ports) == 0 {
@@ -45,4 +52,51 @@ func LoadConfig(filePath string) (Config, error) {
 	}
 
 	return config, nil
-}
\ No newline at end of file
+}
+
+// DecodeConfig parses a target file, as YAML when name ends in .yaml or .yml
+// and as JSON otherwise
+func DecodeConfig(data []byte, name string) (Config, error) {
+	var config Config
+
+	format := "json"
+	var err error
+	switch strings.ToLower(path.Ext(name)) {
+	case ".yaml", ".yml":
+		format = "yaml"
+		err = yaml.Unmarshal(data, &config)
+	default:
+		err = json.Unmarshal(data, &config)
+	}
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse %s config: %w", format, err)
+	}
+
+	if config.Version > CurrentConfigVersion {
+		return Config{}, fmt.Errorf("unsupported config version %d (newest is %d)", config.Version, CurrentConfigVersion)
+	}
+	return config, nil
+}
+
+// LoadProviderSpecs reads the providers file: a JSON list of ProviderSpec
+func LoadProviderSpecs(filePath string) ([]ProviderSpec, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read providers file: %v", err)
+	}
+
+	var specs []ProviderSpec
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return nil, fmt.Errorf("failed to parse providers file: %v", err)
+	}
+
+	for i, spec := range specs {
+		if spec.Type == "" {
+			return nil, fmt.Errorf("invalid providers file: entry %d has no type", i)
+		}
+		if spec.Name == "" {
+			specs[i].Name = spec.Type
+		}
+	}
+	return specs, nil
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig_Legacy(t *testing.T) {
+	conf, err := LoadConfig(writeConfig(t, `{"ports": [443], "domains": ["example.com"]}`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, conf.Version)
+	assert.Equal(t, []string{"example.com"}, conf.Domains)
+}
+
+func TestLoadConfig_RichTargets(t *testing.T) {
+	conf, err := LoadConfig(writeConfig(t, `{
+		"version": 2,
+		"targets": [{
+			"host": "mail.example.com",
+			"ports": [25, 465],
+			"protocol": "smtp",
+			"sni": "mx.example.com",
+			"expected_issuer": "R11",
+			"expected_fingerprint": "AB:CD",
+			"labels": {"env": "prod"},
+			"owner": "team-mail",
+			"alert_days": 21
+		}]
+	}`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultPorts, conf.Ports, "targets without domains still get the default port list")
+	assert.Equal(t, []Target{{
+		Host:                "mail.example.com",
+		Ports:               []int{25, 465},
+		Protocol:            "smtp",
+		SNI:                 "mx.example.com",
+		ExpectedIssuer:      "R11",
+		ExpectedFingerprint: "AB:CD",
+		Labels:              map[string]string{"env": "prod"},
+		Owner:               "team-mail",
+		AlertDays:           21,
+	}}, conf.Targets)
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	_, err := LoadConfig(writeConfig(t, `{"version": 3, "domains": ["example.com"]}`))
+	assert.ErrorContains(t, err, "unsupported config version 3")
+
+	_, err = LoadConfig(writeConfig(t, `{"ports": [443]}`))
+	assert.ErrorContains(t, err, "missing required domains, targets or cidr")
+}
+
+func TestLoadConfig_CIDR(t *testing.T) {
+	conf, err := LoadConfig(writeConfig(t, `{"cidr": ["10.0.0.0/8"]}`))
+	assert.NoError(t, err)
+	assert.Empty(t, conf.Domains, "ranges are expanded lazily by the scanner")
+	assert.Equal(t, []string{"10.0.0.0/8"}, conf.Cidr)
+
+	_, err = LoadConfig(writeConfig(t, `{"cidr": ["10.0.0.0/99"]}`))
+	assert.ErrorContains(t, err, "invalid cidr")
+}
@@ -21,13 +21,33 @@ type AppConfig struct {
 	Split   int
 
 	// Logic Config
-	ConfigType   string // "zone", "config", "gitlab", "cloudflare", "azure" <--- Added azure
+	ConfigType   string // Comma-separated: "zone", "config", "gitlab", "github", "https", "s3", "cloudflare", "cloudflare-certs", "azure", "gcp", "acm", "aws-listeners", "kubernetes", "zonefile", "axfr", "portscan", "terraform", "netbox", "webserver", "docker", "proxyadmin", "vault"
 	PortString   string
 	HostedZoneID string
 
+	// ProvidersFile lists several providers with their own settings, replacing ConfigType
+	ProvidersFile string
+
+	// Route53 zone enumeration
+	Route53AllZones      bool
+	Route53Include       string
+	Route53Exclude       string
+	Route53Tags          string
+	Route53Visibility    string
+	Route53ExpandRouting bool
+
+	// AWS multi-account discovery
+	AWSAccountIDs   string
+	AWSOrganization bool
+	AWSRoleName     string
+	AWSRegions      string
+
 	// Cloudflare
-	CloudflareToken  string
-	CloudflareZoneID string
+	CloudflareToken      string
+	CloudflareZoneID     string
+	CloudflareAccountID  string
+	CloudflareZones      string
+	CloudflareScanOrigin bool
 
 	// Azure Specifics
 	AzureClientID       string // <--- NEW
@@ -36,6 +56,78 @@ type AppConfig struct {
 	AzureSubscriptionID string // <--- NEW
 	AzureResourceGroup  string // <--- NEW
 	AzureDNSZone        string // <--- NEW
+	AzureSubscriptions  string
+
+	// Google Cloud DNS
+	GCPProjects        string
+	GCPCredentialsFile string
+
+	// Kubernetes
+	Kubeconfig     string
+	KubeContext    string
+	KubeNamespaces string
+
+	// BIND zone files and zone transfers
+	ZoneFile      string
+	ZoneOrigin    string
+	AXFRServer    string
+	AXFRZone      string
+	TSIGName      string
+	TSIGSecret    string
+	TSIGAlgorithm string
+
+	// Port scan import
+	PortScanFile string
+	PortScanAll  bool
+
+	// Terraform state
+	TerraformState         string
+	TerraformStateUser     string
+	TerraformStatePassword string
+
+	// CIDR expansion
+	CIDRMaxSize     int
+	CIDRSkipNetwork bool
+	CIDRAllowIPv6   bool
+	Workers         int
+	DiscoverSNI     bool
+
+	// NetBox
+	NetBoxURL       string
+	NetBoxToken     string
+	NetBoxTags      string
+	NetBoxTenants   string
+	NetBoxSites     string
+	NetBoxStatus    string
+	NetBoxPrefixTag string
+
+	// Web server configuration
+	WebServerConfigs string
+	WebServerAddress string
+
+	// Docker Engine
+	DockerHost    string
+	DockerAddress string
+
+	// Envoy / HAProxy admin APIs
+	EnvoyAdmin     string
+	HAProxySockets string
+
+	// Vault PKI
+	VaultAddr         string
+	VaultToken        string
+	VaultRoleID       string
+	VaultSecretID     string
+	VaultAppRoleMount string
+	VaultNamespace    string
+	VaultMounts       string
+
+	// Scope guards applied to every discovered target
+	Include         string
+	Exclude         string
+	DenylistFile    string
+	MaxTargets      int
+	ExclusionReport string
 
 	// Alerting
 	PagerDutyKey string
@@ -50,13 +142,68 @@ type AppConfig struct {
 	GitlabProjectID string
 	GitlabFilePath  string
 	GitlabRef       string
+	GitlabGroup     string
+	GitlabGroupFile string
+
+	// GitHub
+	GitHubToken string
+	GitHubURL   string
+	GitHubRepo  string
+	GitHubPath  string
+	GitHubRef   string
+
+	// HTTP(S) config URL
+	ConfigURL         string
+	ConfigURLToken    string
+	ConfigURLUser     string
+	ConfigURLPassword string
+	ConfigURLCacheDir string
+
+	// S3 config object
+	S3URL      string
+	S3Region   string
+	S3Endpoint string
 }
 
 // Load parses flags and environment variables
 func Load(args []string) (*AppConfig, error) {
-	fs := flag.NewFlagSet("ssl_checker", flag.ExitOnError)
-
 	var cfg AppConfig
+	fs := newFlagSet(&cfg, flag.ExitOnError)
+
+	err := ff.Parse(fs, args,
+		ff.WithEnvVarPrefix("SSL"),
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("error parsing configuration: %w", err)
+	}
+
+	return &cfg, nil
+}
+
+// WithOverrides returns a copy of the configuration with the given flags
+// (by flag name, e.g. "cloudflaretoken") set, so one run can hold several
+// providers that each carry their own credentials
+func (c *AppConfig) WithOverrides(settings map[string]string) (*AppConfig, error) {
+	var out AppConfig
+	fs := newFlagSet(&out, flag.ContinueOnError)
+
+	// The flag set points into out, so copy the base values in before applying overrides
+	out = *c
+	for name, value := range settings {
+		if fs.Lookup(name) == nil {
+			return nil, fmt.Errorf("unknown setting: %s", name)
+		}
+		if err := fs.Set(name, value); err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %w", name, err)
+		}
+	}
+	return &out, nil
+}
+
+// newFlagSet registers every flag on cfg, which receives the defaults
+func newFlagSet(cfg *AppConfig, handling flag.ErrorHandling) *flag.FlagSet {
+	fs := flag.NewFlagSet("ssl_checker", handling)
 
 	// ... existing flags ...
 	fs.StringVar(&cfg.ConfigFile, "config", "", "Path to the configuration file")
@@ -66,22 +213,107 @@ func Load(args []string) (*AppConfig, error) {
 
 	fs.DurationVar(&cfg.Timeout, "timeout", 5*time.Second, "Timeout for connection attempts")
 	fs.IntVar(&cfg.Split, "split", 30, "Number of domains to test per thread")
+	fs.IntVar(&cfg.Workers, "workers", 64, "Maximum number of threads scanning at once")
 
-	fs.StringVar(&cfg.ConfigType, "type", "gitlab", "Which config to use: zone, config, gitlab, cloudflare, azure")
+	fs.IntVar(&cfg.CIDRMaxSize, "cidrmaxsize", 65536, "Refuse CIDR ranges with more addresses than this (0 disables the limit)")
+	fs.BoolVar(&cfg.CIDRSkipNetwork, "cidrskipnetwork", false, "Skip the network and broadcast address of IPv4 ranges")
+	fs.BoolVar(&cfg.CIDRAllowIPv6, "cidripv6", false, "Allow IPv6 CIDR ranges")
+	fs.BoolVar(&cfg.DiscoverSNI, "discoversni", false, "For IP targets, also scan every name in their PTR records and default certificate as its own virtual host")
+
+	fs.StringVar(&cfg.ConfigType, "type", "gitlab", "Which config to use, comma-separated to combine several: zone, config, gitlab, github, https, s3, cloudflare, cloudflare-certs, azure, gcp, acm, aws-listeners, kubernetes, zonefile, axfr, portscan, terraform, netbox, webserver, docker, proxyadmin, vault")
 	fs.StringVar(&cfg.PortString, "ports", "", "Comma-separated list of ports")
+	fs.StringVar(&cfg.ProvidersFile, "providers", "", "JSON file listing providers to combine, each with its own type and settings (overrides -type)")
 	fs.StringVar(&cfg.HostedZoneID, "hosted-zone-id", "", "Route53 Hosted Zone ID")
+	fs.BoolVar(&cfg.Route53AllZones, "route53-all-zones", false, "Walk every Route53 hosted zone in the account instead of -hosted-zone-id")
+	fs.StringVar(&cfg.Route53Include, "route53-include", "", "Comma-separated zone name globs to include")
+	fs.StringVar(&cfg.Route53Exclude, "route53-exclude", "", "Comma-separated zone name globs to exclude")
+	fs.StringVar(&cfg.Route53Tags, "route53-tags", "", "Comma-separated key=value tags a zone must carry")
+	fs.StringVar(&cfg.Route53Visibility, "route53-visibility", "all", "Which zones to walk: all, public, private")
+	fs.BoolVar(&cfg.Route53ExpandRouting, "route53-expand-routing", false, "Scan each weighted/latency/failover record value individually, using the record name as SNI")
+	fs.StringVar(&cfg.AWSAccountIDs, "aws-accounts", "", "Comma-separated AWS account IDs to visit via -aws-role-name")
+	fs.BoolVar(&cfg.AWSOrganization, "aws-organization", false, "Visit every active account of the AWS Organization")
+	fs.StringVar(&cfg.AWSRoleName, "aws-role-name", "", "IAM role name assumed in each AWS account")
+	fs.StringVar(&cfg.AWSRegions, "aws-regions", "", "Comma-separated regions whose ACM certificates and load balancers are inventoried (defaults to the session region)")
 
 	// Cloudflare
 	fs.StringVar(&cfg.CloudflareToken, "cloudflaretoken", "", "Cloudflare API Token")
 	fs.StringVar(&cfg.CloudflareZoneID, "cloudflarezoneid", "", "Cloudflare Zone ID")
+	fs.StringVar(&cfg.CloudflareAccountID, "cloudflareaccountid", "", "Cloudflare Account ID (walks every zone of the account)")
+	fs.StringVar(&cfg.CloudflareZones, "cloudflarezones", "", "Comma-separated zone name globs to include when walking the account")
+	fs.BoolVar(&cfg.CloudflareScanOrigin, "cloudflarescanorigin", false, "Also scan proxied records at their origin, using the hostname as SNI")
 
 	// Azure
-	fs.StringVar(&cfg.AzureClientID, "azureclientid", "", "Azure Client ID")
+	fs.StringVar(&cfg.AzureClientID, "azureclientid", "", "Azure Client ID (leave the client secret fields empty to use DefaultAzureCredential)")
 	fs.StringVar(&cfg.AzureClientSecret, "azureclientsecret", "", "Azure Client Secret")
 	fs.StringVar(&cfg.AzureTenantID, "azuretenantid", "", "Azure Tenant ID")
 	fs.StringVar(&cfg.AzureSubscriptionID, "azuresubscriptionid", "", "Azure Subscription ID")
 	fs.StringVar(&cfg.AzureResourceGroup, "azureresourcegroup", "", "Azure Resource Group Name")
 	fs.StringVar(&cfg.AzureDNSZone, "azurezone", "", "Azure DNS Zone Name")
+	fs.StringVar(&cfg.AzureSubscriptions, "azuresubscriptions", "", "Comma-separated subscription IDs whose public and private DNS zones are all walked")
+
+	// Google Cloud DNS
+	fs.StringVar(&cfg.GCPProjects, "gcpprojects", "", "Comma-separated GCP project IDs whose Cloud DNS managed zones are walked")
+	fs.StringVar(&cfg.GCPCredentialsFile, "gcpcredentials", "", "Service account JSON key file (leave empty to use Application Default Credentials / workload identity)")
+
+	// Kubernetes
+	fs.StringVar(&cfg.Kubeconfig, "kubeconfig", "", "Path to a kubeconfig file (leave empty for in-cluster credentials or the default kubeconfig)")
+	fs.StringVar(&cfg.KubeContext, "kubecontext", "", "Kubeconfig context to use")
+	fs.StringVar(&cfg.KubeNamespaces, "kubenamespaces", "", "Comma-separated namespaces to walk (default: all)")
+
+	// BIND zone files and zone transfers
+	fs.StringVar(&cfg.ZoneFile, "zonefile", "", "Path to an RFC 1035 zone file ($ORIGIN and $INCLUDE are followed)")
+	fs.StringVar(&cfg.ZoneOrigin, "zoneorigin", "", "Origin for relative names until the zone file sets $ORIGIN")
+	fs.StringVar(&cfg.AXFRServer, "axfrserver", "", "Nameserver (host or host:port) to request the zone transfer from")
+	fs.StringVar(&cfg.AXFRZone, "axfrzone", "", "Zone to transfer")
+	fs.StringVar(&cfg.TSIGName, "tsigname", "", "TSIG key name (leave empty for an unsigned transfer)")
+	fs.StringVar(&cfg.TSIGSecret, "tsigsecret", "", "Base64 TSIG key secret")
+	fs.StringVar(&cfg.TSIGAlgorithm, "tsigalgorithm", "hmac-sha256", "TSIG algorithm")
+
+	// Port scan import
+	fs.StringVar(&cfg.PortScanFile, "portscanfile", "", "Nmap XML (-oX) or masscan JSON (-oJ) file whose open TLS ports are scanned")
+	fs.BoolVar(&cfg.PortScanAll, "portscanall", false, "Import every open TCP port, not only TLS and STARTTLS services")
+
+	// Terraform state
+	fs.StringVar(&cfg.TerraformState, "tfstate", "", "Comma-separated .tfstate paths or http(s) URLs of remote state")
+	fs.StringVar(&cfg.TerraformStateUser, "tfstateuser", "", "Basic auth username for remote state")
+	fs.StringVar(&cfg.TerraformStatePassword, "tfstatepassword", "", "Basic auth password for remote state")
+
+	// NetBox
+	fs.StringVar(&cfg.NetBoxURL, "netboxurl", "", "NetBox URL")
+	fs.StringVar(&cfg.NetBoxToken, "netboxtoken", "", "NetBox API Token")
+	fs.StringVar(&cfg.NetBoxTags, "netboxtags", "", "Comma-separated tags every IP address, service and prefix must carry")
+	fs.StringVar(&cfg.NetBoxTenants, "netboxtenants", "", "Comma-separated tenant slugs to include")
+	fs.StringVar(&cfg.NetBoxSites, "netboxsites", "", "Comma-separated site slugs to include")
+	fs.StringVar(&cfg.NetBoxStatus, "netboxstatus", "active", "Comma-separated IP address and prefix statuses to include")
+	fs.StringVar(&cfg.NetBoxPrefixTag, "netboxprefixtag", "ssl-scan", "Tag marking the prefixes to expand and scan (empty skips prefixes)")
+
+	// Web server configuration
+	fs.StringVar(&cfg.WebServerConfigs, "webserverconfigs", "", "Comma-separated nginx, Apache or HAProxy main config files or globs; includes are followed")
+	fs.StringVar(&cfg.WebServerAddress, "webserveraddress", "127.0.0.1", "Address to dial vhosts that listen on every interface")
+
+	// Docker Engine
+	fs.StringVar(&cfg.DockerHost, "dockerhost", "", "Docker Engine address, unix:// or tcp:// (defaults to $DOCKER_HOST, then the local socket)")
+	fs.StringVar(&cfg.DockerAddress, "dockeraddress", "127.0.0.1", "Address to dial ports published on every interface")
+
+	// Envoy / HAProxy admin APIs
+	fs.StringVar(&cfg.EnvoyAdmin, "envoyadmin", "", "Comma-separated Envoy admin URLs whose /certs are inventoried")
+	fs.StringVar(&cfg.HAProxySockets, "haproxysocket", "", "Comma-separated HAProxy runtime API sockets (unix socket path or host:port)")
+
+	// Vault PKI
+	fs.StringVar(&cfg.VaultAddr, "vaultaddr", "", "Vault address (defaults to $VAULT_ADDR)")
+	fs.StringVar(&cfg.VaultToken, "vaulttoken", "", "Vault token (defaults to $VAULT_TOKEN)")
+	fs.StringVar(&cfg.VaultRoleID, "vaultroleid", "", "AppRole role ID; logs in instead of using a token")
+	fs.StringVar(&cfg.VaultSecretID, "vaultsecretid", "", "AppRole secret ID")
+	fs.StringVar(&cfg.VaultAppRoleMount, "vaultapprolemount", "approle", "AppRole auth mount path")
+	fs.StringVar(&cfg.VaultNamespace, "vaultnamespace", "", "Vault Enterprise namespace")
+	fs.StringVar(&cfg.VaultMounts, "vaultmounts", "pki", "Comma-separated PKI mounts whose issued certificates are listed")
+
+	// Scope guards
+	fs.StringVar(&cfg.Include, "include", "", "Comma-separated rules a target must match one of: glob:, regex:, cidr:, label:key=value (bare values are globs or CIDRs)")
+	fs.StringVar(&cfg.Exclude, "exclude", "", "Comma-separated rules of targets to skip, same syntax as -include")
+	fs.StringVar(&cfg.DenylistFile, "denylist", "", "File of never-scan rules, one per line; wins over -include")
+	fs.IntVar(&cfg.MaxTargets, "maxtargets", 0, "Abort when more targets than this remain after filtering (0 disables the cap)")
+	fs.StringVar(&cfg.ExclusionReport, "exclusionreport", "", "Write the excluded targets and the reason to this CSV file")
 
 	// ... Alerting & Gitlab flags ...
 	fs.StringVar(&cfg.PagerDutyKey, "pagerdutykey", "", "PagerDuty Integration Key")
@@ -93,16 +325,26 @@ func Load(args []string) (*AppConfig, error) {
 	fs.StringVar(&cfg.GitlabToken, "gitlabtoken", "", "Gitlab Token")
 	fs.StringVar(&cfg.GitlabURL, "gitlaburl", "", "Gitlab Instance URL")
 	fs.StringVar(&cfg.GitlabProjectID, "gitlabprojectid", "", "Gitlab Project ID")
-	fs.StringVar(&cfg.GitlabFilePath, "gitlabfilepath", "", "Path to YAML file in repo")
-	fs.StringVar(&cfg.GitlabRef, "gitlabref", "", "Branch or commit SHA")
+	fs.StringVar(&cfg.GitlabFilePath, "gitlabfilepath", "", "Comma-separated paths or globs (e.g. targets/**/*.yaml) of JSON or YAML target files in the repo")
+	fs.StringVar(&cfg.GitlabRef, "gitlabref", "", "Branch or commit SHA (group projects default to their default branch)")
+	fs.StringVar(&cfg.GitlabGroup, "gitlabgroup", "", "Gitlab group ID or path whose projects (and subgroups) are searched for -gitlabgroupfile")
+	fs.StringVar(&cfg.GitlabGroupFile, "gitlabgroupfile", "ssl-targets.yaml", "Target file name looked up in every project of -gitlabgroup")
 
-	err := ff.Parse(fs, args,
-		ff.WithEnvVarPrefix("SSL"),
-	)
+	fs.StringVar(&cfg.GitHubToken, "githubtoken", "", "GitHub Token")
+	fs.StringVar(&cfg.GitHubURL, "githuburl", "", "GitHub Enterprise API URL (default https://api.github.com)")
+	fs.StringVar(&cfg.GitHubRepo, "githubrepo", "", "GitHub repository as owner/name")
+	fs.StringVar(&cfg.GitHubPath, "githubpath", "", "Path to the JSON or YAML target file in the repo")
+	fs.StringVar(&cfg.GitHubRef, "githubref", "", "Branch, tag or commit SHA (default branch when empty)")
 
-	if err != nil {
-		return nil, fmt.Errorf("error parsing configuration: %w", err)
-	}
+	fs.StringVar(&cfg.ConfigURL, "configurl", "", "HTTP(S) URL of a JSON or YAML target file")
+	fs.StringVar(&cfg.ConfigURLToken, "configurltoken", "", "Bearer token sent to -configurl")
+	fs.StringVar(&cfg.ConfigURLUser, "configurluser", "", "Basic auth username for -configurl")
+	fs.StringVar(&cfg.ConfigURLPassword, "configurlpassword", "", "Basic auth password for -configurl")
+	fs.StringVar(&cfg.ConfigURLCacheDir, "configurlcache", "", "Directory caching -configurl with its ETag (default: the user cache dir)")
 
-	return &cfg, nil
+	fs.StringVar(&cfg.S3URL, "s3url", "", "S3 object holding a JSON or YAML target file, as s3://bucket/key")
+	fs.StringVar(&cfg.S3Region, "s3region", "", "Region of the bucket (default: the AWS session region)")
+	fs.StringVar(&cfg.S3Endpoint, "s3endpoint", "", "S3-compatible endpoint such as MinIO (uses path-style addressing)")
+
+	return fs
 }
@@ -94,3 +94,17 @@ func TestLoad(t *testing.T) {
 		})
 	}
 }
+
+func TestWithOverrides(t *testing.T) {
+	base := &AppConfig{ConfigType: "zone", CloudflareToken: "shared", Timeout: 5 * time.Second}
+
+	got, err := base.WithOverrides(map[string]string{"cloudflaretoken": "team-b", "timeout": "10s"})
+	assert.NoError(t, err)
+	assert.Equal(t, "team-b", got.CloudflareToken)
+	assert.Equal(t, 10*time.Second, got.Timeout)
+	assert.Equal(t, "zone", got.ConfigType, "settings not overridden are kept")
+	assert.Equal(t, "shared", base.CloudflareToken, "the base configuration is untouched")
+
+	_, err = base.WithOverrides(map[string]string{"nosuchflag": "x"})
+	assert.Error(t, err)
+}
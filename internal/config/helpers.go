@@ -5,8 +5,6 @@ import (
 	"sort"
 	"strconv"
 	"strings"
-
-	"github.com/3th1nk/cidr"
 )
 
 // ParsePorts parses a comma-separated string of ports into a slice of ints
@@ -41,22 +39,101 @@ func MergePorts(configPorts, cliPorts []int) []int {
 		mergedPorts = append(mergedPorts, port)
 	}
 
-	sort.Ints(mergedPorts) 
+	sort.Ints(mergedPorts)
 	return mergedPorts
 }
 
-// ConvertCidrToIPList converts a CIDR string (e.g., "10.0.0.1/24") to a list of IPs
+// ConvertCidrToIPList converts a CIDR string (e.g., "10.0.0.1/24") to a list of IPs.
+// Large ranges should be walked with ExpandCIDR instead.
 func ConvertCidrToIPList(ip string) ([]string, error) {
-	// FIX: Capture the error here instead of using '_'
-	c, err := cidr.Parse(ip)
+	seq, _, err := ExpandCIDR(ip, CIDROptions{AllowIPv6: true})
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse CIDR: %w", err)
+		return nil, err
 	}
-	
+
 	var ips []string
-	c.Each(func(ip string) bool {
+	for ip := range seq {
 		ips = append(ips, ip)
-		return true
-	})
+	}
 	return ips, nil
-}
\ No newline at end of file
+}
+
+// SplitList splits a comma-separated string into trimmed, non-empty values
+func SplitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// ParseLabels parses a comma-separated list of key=value pairs into a map
+func ParseLabels(s string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, pair := range SplitList(s) {
+		k, v, ok := strings.Cut(pair, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid label value: %s", pair)
+		}
+		labels[k] = strings.TrimSpace(v)
+	}
+	return labels, nil
+}
+
+// FormatLabels renders labels as sorted key=value pairs joined by ";"
+func FormatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+labels[k])
+	}
+	return strings.Join(pairs, ";")
+}
+
+// WithLabel returns a copy of labels with one more key set
+func WithLabel(labels map[string]string, key, value string) map[string]string {
+	out := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		out[k] = v
+	}
+	out[key] = value
+	return out
+}
+
+// NormalizeHost lowercases a hostname and strips surrounding whitespace and the trailing root dot
+func NormalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
+
+// PortsFor returns the target's own ports, falling back to the global list
+func (t Target) PortsFor(defaults []int) []int {
+	if len(t.Ports) > 0 {
+		return t.Ports
+	}
+	return defaults
+}
+
+// AlertThreshold returns the row's own alert threshold, falling back to the global one
+func (r DomainValidity) AlertThreshold(defaultDays int) int {
+	if r.AlertDays > 0 {
+		return r.AlertDays
+	}
+	return defaultDays
+}
+
+// AllTargets returns the plain domain list and the tagged targets as one slice
+func (c Config) AllTargets() []Target {
+	targets := make([]Target, 0, len(c.Domains)+len(c.Targets))
+	for _, d := range c.Domains {
+		targets = append(targets, Target{Host: d})
+	}
+	return append(targets, c.Targets...)
+}
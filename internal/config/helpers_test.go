@@ -169,3 +169,105 @@ func TestConvertCidrToIPList(t *testing.T) {
 		})
 	}
 }
+
+func TestSplitList(t *testing.T) {
+	got := SplitList(" a, b ,,c ")
+	if !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("SplitList() = %v", got)
+	}
+	if got := SplitList(""); got != nil {
+		t.Errorf("SplitList(\"\") = %v, want nil", got)
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		want      map[string]string
+		expectErr bool
+	}{
+		{
+			name:  "Multiple pairs",
+			input: "env=prod, team = core",
+			want:  map[string]string{"env": "prod", "team": "core"},
+		},
+		{
+			name:  "Empty value",
+			input: "env=",
+			want:  map[string]string{"env": ""},
+		},
+		{
+			name:  "Empty string",
+			input: "",
+			want:  map[string]string{},
+		},
+		{
+			name:      "Missing separator",
+			input:     "env",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLabels(tt.input)
+			if (err != nil) != tt.expectErr {
+				t.Errorf("ParseLabels() error = %v, expectErr %v", err, tt.expectErr)
+				return
+			}
+			if !tt.expectErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLabels() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatLabels(t *testing.T) {
+	got := FormatLabels(map[string]string{"zone": "example.com", "account": "123"})
+	if got != "account=123;zone=example.com" {
+		t.Errorf("FormatLabels() = %s", got)
+	}
+}
+
+func TestWithLabel(t *testing.T) {
+	labels := map[string]string{"zone": "example.com"}
+	got := WithLabel(labels, "source", "san")
+	if !reflect.DeepEqual(got, map[string]string{"zone": "example.com", "source": "san"}) {
+		t.Errorf("WithLabel() = %v", got)
+	}
+	if len(labels) != 1 {
+		t.Errorf("WithLabel() modified its input: %v", labels)
+	}
+}
+
+func TestNormalizeHost(t *testing.T) {
+	for input, want := range map[string]string{
+		"WWW.Example.com.": "www.example.com",
+		" api.example.com": "api.example.com",
+		"10.0.0.1":         "10.0.0.1",
+	} {
+		if got := NormalizeHost(input); got != want {
+			t.Errorf("NormalizeHost(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestTargetPortsFor(t *testing.T) {
+	defaults := []int{443}
+	if got := (Target{Host: "a"}).PortsFor(defaults); !reflect.DeepEqual(got, defaults) {
+		t.Errorf("PortsFor() without own ports = %v, want %v", got, defaults)
+	}
+	if got := (Target{Host: "a", Ports: []int{8443}}).PortsFor(defaults); !reflect.DeepEqual(got, []int{8443}) {
+		t.Errorf("PortsFor() with own ports = %v, want [8443]", got)
+	}
+}
+
+func TestAlertThreshold(t *testing.T) {
+	if got := (DomainValidity{}).AlertThreshold(14); got != 14 {
+		t.Errorf("AlertThreshold() without own threshold = %d, want 14", got)
+	}
+	if got := (DomainValidity{AlertDays: 30}).AlertThreshold(14); got != 30 {
+		t.Errorf("AlertThreshold() with own threshold = %d, want 30", got)
+	}
+}
@@ -4,11 +4,40 @@ import (
 	"time"
 )
 
+// CurrentConfigVersion is the newest config file schema. Version 1 (or no
+// version) is the flat ports/domains/cidr format; version 2 adds rich targets.
+const CurrentConfigVersion = 2
+
 // Config holds the actual domains/ports to check
 type Config struct {
+	Version int      `json:"version,omitempty"`
 	Ports   []int    `json:"ports"`
 	Domains []string `json:"domains"`
 	Cidr    []string `json:"cidr"`
+	Targets []Target `json:"targets,omitempty"`
+
+	// Results holds rows produced directly by discovery (e.g. per-account
+	// failures) that are reported alongside the scan without a handshake
+	Results []DomainValidity `json:"-"`
+}
+
+// Target is a single host to scan, tagged with where it was discovered
+type Target struct {
+	Host     string            `json:"host"`
+	Address  string            `json:"address,omitempty"`  // Where to connect when it differs from Host (Host is still sent as SNI)
+	Ports    []int             `json:"ports,omitempty"`    // Ports of this target; empty means the global port list
+	Protocol string            `json:"protocol,omitempty"` // STARTTLS protocol (smtp, imap, pop3, ftp); empty for direct TLS
+	SNI      string            `json:"sni,omitempty"`      // Server name to send and verify instead of Host
+	Labels   map[string]string `json:"labels,omitempty"`
+
+	ExpectedIssuer      string `json:"expected_issuer,omitempty"`      // Flag the certificate when its issuer differs
+	ExpectedFingerprint string `json:"expected_fingerprint,omitempty"` // SHA-256 of the leaf, hex with or without colons; comma-separated to accept any of several
+	Owner               string `json:"owner,omitempty"`                // Team or person responsible for the certificate
+	AlertDays           int    `json:"alert_days,omitempty"`           // Overrides the global -alertdays for this target
+
+	// DiscoverSNI makes an IP target also scan every name found in its PTR
+	// records and default certificate, each as its own virtual host
+	DiscoverSNI bool `json:"discover_sni,omitempty"`
 }
 
 // DomainValidity holds the scan results
@@ -28,9 +57,24 @@ type DomainValidity struct {
 	SANs          []string `json:"sans"`           // List of all valid domains
 	// ------------------
 
+	Labels map[string]string `json:"labels,omitempty"` // Discovery tags (zone, account, ...)
+	Source string            `json:"source,omitempty"` // Set when the row comes from discovery, not a handshake
+
+	Fingerprint string `json:"fingerprint,omitempty"` // SHA-256 of the leaf certificate
+	Owner       string `json:"owner,omitempty"`
+	AlertDays   int    `json:"alert_days,omitempty"` // Per-target threshold; 0 uses the global one
+
 	NotBefore       time.Time `json:"not_before"`
 	NotAfter        time.Time `json:"not_after"`
 	DaysUntilExpiry int       `json:"days_until_expiry"`
 	CommonName      string    `json:"common_name"`
 	Error           string    `json:"error,omitempty"`
 }
+
+// ProviderSpec is one entry of the providers file. Settings are flag names
+// (e.g. "cloudflaretoken") overriding the run's configuration for this provider.
+type ProviderSpec struct {
+	Name     string            `json:"name"`
+	Type     string            `json:"type"`
+	Settings map[string]string `json:"settings,omitempty"`
+}
@@ -62,7 +62,8 @@ func WriteOutputToFile(outputFile string, data []DomainValidity) error {
 		"Domain", "IP Address", "Port",
 		"TLS Version", "Cipher Suite", "FIPS Compliant",
 		"Chain Status", "Issuer", "Sig Algo", "SANs", // <--- New Headers
-		"Serial", "Common Name", "Not Before", "Not After", "Days until Expire", "Error",
+		"Serial", "Common Name", "Not Before", "Not After", "Days until Expire", "Error", "Labels",
+		"Owner", "Fingerprint",
 	}
 	w.Write(csvRow)
 	sw.Write(csvRow)
@@ -98,6 +99,9 @@ func WriteOutputToFile(outputFile string, data []DomainValidity) error {
 			fmt.Sprint(r.NotAfter),
 			fmt.Sprint(r.DaysUntilExpiry),
 			r.Error,
+			FormatLabels(r.Labels),
+			r.Owner,
+			r.Fingerprint,
 		)
 
 		w.Write(csvRow)
@@ -0,0 +1,311 @@
+package config
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"iter"
+	"net"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ScopeRule matches targets by hostname glob, regex, CIDR or label. Rules are
+// written as "kind:pattern" (glob:, regex:, cidr:, label:); without a kind,
+// IPs and CIDRs are CIDR rules and anything else is a glob.
+type ScopeRule struct {
+	Kind    string
+	Pattern string
+
+	re         *regexp.Regexp
+	network    *net.IPNet
+	labelKey   string
+	labelValue string
+	anyValue   bool
+}
+
+// Scope decides which discovered targets may be scanned. The denylist always
+// wins; when include rules are set a target must match one of them.
+type Scope struct {
+	Include    []ScopeRule
+	Exclude    []ScopeRule
+	Deny       []ScopeRule
+	MaxTargets int // 0 means no cap
+
+	// Excluded collects every dropped target, in the order they were seen.
+	// Addresses of streamed CIDR ranges are counted per reason and rule instead.
+	Excluded []Exclusion
+
+	streamed map[string]int // index in Excluded of each reason and rule seen by Filter
+}
+
+// Exclusion records a target that was dropped and the rule responsible
+type Exclusion struct {
+	Host    string
+	Address string
+	Reason  string // denylist, exclude, not-included
+	Rule    string
+	Count   int // CIDR addresses dropped by the rule; 0 for a single target
+}
+
+// Targets returns how many targets the exclusion stands for
+func (e Exclusion) Targets() int {
+	return max(e.Count, 1)
+}
+
+// ParseScopeRule parses a single "kind:pattern" rule
+func ParseScopeRule(s string) (ScopeRule, error) {
+	s = strings.TrimSpace(s)
+	kind, pattern, ok := strings.Cut(s, ":")
+	switch kind {
+	case "glob", "regex", "cidr", "label":
+	default:
+		// IPv6 addresses contain colons, so only known kinds are treated as prefixes
+		kind, pattern, ok = "", s, true
+		if _, _, err := net.ParseCIDR(s); err == nil || net.ParseIP(s) != nil {
+			kind = "cidr"
+		} else {
+			kind = "glob"
+		}
+	}
+	if !ok || pattern == "" {
+		return ScopeRule{}, fmt.Errorf("invalid scope rule: %s", s)
+	}
+
+	rule := ScopeRule{Kind: kind, Pattern: pattern}
+	switch kind {
+	case "glob":
+		rule.Pattern = strings.ToLower(pattern)
+		if _, err := path.Match(rule.Pattern, ""); err != nil {
+			return ScopeRule{}, fmt.Errorf("invalid glob %q: %w", pattern, err)
+		}
+	case "regex":
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return ScopeRule{}, fmt.Errorf("invalid regex %q: %w", pattern, err)
+		}
+		rule.re = re
+	case "cidr":
+		if ip := net.ParseIP(pattern); ip != nil {
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			rule.network = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+		} else {
+			_, network, err := net.ParseCIDR(pattern)
+			if err != nil {
+				return ScopeRule{}, fmt.Errorf("invalid cidr %q: %w", pattern, err)
+			}
+			rule.network = network
+		}
+	case "label":
+		k, v, hasValue := strings.Cut(pattern, "=")
+		rule.labelKey, rule.labelValue, rule.anyValue = strings.TrimSpace(k), strings.TrimSpace(v), !hasValue
+		if rule.labelKey == "" {
+			return ScopeRule{}, fmt.Errorf("invalid label selector: %s", pattern)
+		}
+	}
+	return rule, nil
+}
+
+// ParseScopeRules parses a comma-separated list of rules
+func ParseScopeRules(s string) ([]ScopeRule, error) {
+	var rules []ScopeRule
+	for _, part := range SplitList(s) {
+		rule, err := ParseScopeRule(part)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// LoadDenylist reads one rule per line; blank lines and # comments are ignored
+func LoadDenylist(filePath string) ([]ScopeRule, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read denylist: %v", err)
+	}
+	defer file.Close()
+
+	var rules []ScopeRule
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		if text = strings.TrimSpace(text); text == "" {
+			continue
+		}
+		rule, err := ParseScopeRule(text)
+		if err != nil {
+			return nil, fmt.Errorf("denylist line %d: %w", line, err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read denylist: %v", err)
+	}
+	return rules, nil
+}
+
+// NewScope builds the scope from the -include, -exclude, -denylist and -maxtargets flags
+func NewScope(cfg *AppConfig) (*Scope, error) {
+	include, err := ParseScopeRules(cfg.Include)
+	if err != nil {
+		return nil, fmt.Errorf("include: %w", err)
+	}
+	exclude, err := ParseScopeRules(cfg.Exclude)
+	if err != nil {
+		return nil, fmt.Errorf("exclude: %w", err)
+	}
+
+	scope := &Scope{Include: include, Exclude: exclude, MaxTargets: cfg.MaxTargets}
+	if cfg.DenylistFile != "" {
+		if scope.Deny, err = LoadDenylist(cfg.DenylistFile); err != nil {
+			return nil, err
+		}
+	}
+	return scope, nil
+}
+
+func (r ScopeRule) String() string {
+	return r.Kind + ":" + r.Pattern
+}
+
+// Matches reports whether the rule selects the target. Name rules look at the
+// host and SNI, CIDR rules at the host and connect address when they are IPs.
+func (r ScopeRule) Matches(t Target) bool {
+	switch r.Kind {
+	case "glob":
+		for _, name := range []string{t.Host, t.SNI} {
+			if ok, _ := path.Match(r.Pattern, NormalizeHost(name)); ok && name != "" {
+				return true
+			}
+		}
+	case "regex":
+		return r.re.MatchString(t.Host) || (t.SNI != "" && r.re.MatchString(t.SNI))
+	case "cidr":
+		for _, addr := range []string{t.Host, t.Address} {
+			if ip := net.ParseIP(addr); ip != nil && r.network.Contains(ip) {
+				return true
+			}
+		}
+	case "label":
+		v, ok := t.Labels[r.labelKey]
+		return ok && (r.anyValue || v == r.labelValue)
+	}
+	return false
+}
+
+// check returns why the target is out of scope, or an empty reason
+func (s *Scope) check(t Target) (reason string, rule string) {
+	for _, r := range s.Deny {
+		if r.Matches(t) {
+			return "denylist", r.String()
+		}
+	}
+	for _, r := range s.Exclude {
+		if r.Matches(t) {
+			return "exclude", r.String()
+		}
+	}
+	if len(s.Include) == 0 {
+		return "", ""
+	}
+	for _, r := range s.Include {
+		if r.Matches(t) {
+			return "", ""
+		}
+	}
+	return "not-included", ""
+}
+
+// Apply drops out-of-scope domains and targets from conf and records each of
+// them in Excluded
+func (s *Scope) Apply(conf *Config) {
+	domains := conf.Domains[:0]
+	for _, d := range conf.Domains {
+		if s.allows(Target{Host: d}) {
+			domains = append(domains, d)
+		}
+	}
+	conf.Domains = domains
+
+	targets := conf.Targets[:0]
+	for _, t := range conf.Targets {
+		if s.allows(t) {
+			targets = append(targets, t)
+		}
+	}
+	conf.Targets = targets
+}
+
+// Filter applies the scope to a stream of targets, such as expanded CIDR
+// ranges. A range can hold millions of addresses, so what it drops is counted
+// in one Excluded entry per reason and rule. The returned sequence must not be
+// consumed concurrently.
+func (s *Scope) Filter(targets iter.Seq[Target]) iter.Seq[Target] {
+	return func(yield func(Target) bool) {
+		for t := range targets {
+			reason, rule := s.check(t)
+			if reason == "" {
+				if !yield(t) {
+					return
+				}
+				continue
+			}
+
+			key := reason + "|" + rule
+			if i, ok := s.streamed[key]; ok {
+				s.Excluded[i].Count++
+				continue
+			}
+			if s.streamed == nil {
+				s.streamed = make(map[string]int)
+			}
+			s.streamed[key] = len(s.Excluded)
+			s.Excluded = append(s.Excluded, Exclusion{Host: "cidr", Reason: reason, Rule: rule, Count: 1})
+		}
+	}
+}
+
+// CheckCap fails when more targets than MaxTargets would be scanned
+func (s *Scope) CheckCap(total uint64) error {
+	if s.MaxTargets > 0 && total > uint64(s.MaxTargets) {
+		return fmt.Errorf("%d targets exceed the safety cap of %d (-maxtargets)", total, s.MaxTargets)
+	}
+	return nil
+}
+
+func (s *Scope) allows(t Target) bool {
+	reason, rule := s.check(t)
+	if reason == "" {
+		return true
+	}
+	s.Excluded = append(s.Excluded, Exclusion{Host: t.Host, Address: t.Address, Reason: reason, Rule: rule})
+	return false
+}
+
+// WriteExclusionReport writes the excluded targets as CSV
+func WriteExclusionReport(filePath string, excluded []Exclusion) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("error creating exclusion report: %v", err)
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	w.Write([]string{"Host", "Address", "Reason", "Rule", "Count"})
+	for _, e := range excluded {
+		w.Write([]string{e.Host, e.Address, e.Reason, e.Rule, strconv.Itoa(e.Targets())})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("error writing exclusion report: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseScopeRule(t *testing.T) {
+	tests := []struct {
+		rule   string
+		target Target
+		want   bool
+	}{
+		{"*.internal.example.com", Target{Host: "db.internal.example.com"}, true},
+		{"*.internal.example.com", Target{Host: "www.example.com"}, false},
+		{"glob:edge.example.com", Target{Host: "10.0.0.1", SNI: "Edge.Example.com"}, true},
+		{"regex:^_acme-challenge\\.", Target{Host: "_ACME-challenge.example.com"}, true},
+		{"10.0.0.0/8", Target{Host: "10.1.2.3"}, true},
+		{"cidr:10.0.0.0/8", Target{Host: "www.example.com", Address: "10.1.2.3"}, true},
+		{"192.0.2.7", Target{Host: "192.0.2.7"}, true},
+		{"2001:db8::/32", Target{Host: "2001:db8::1"}, true},
+		{"label:env=dev", Target{Host: "a", Labels: map[string]string{"env": "dev"}}, true},
+		{"label:env=dev", Target{Host: "a", Labels: map[string]string{"env": "prod"}}, false},
+		{"label:k8s_namespace", Target{Host: "a", Labels: map[string]string{"k8s_namespace": "shop"}}, true},
+	}
+
+	for _, tt := range tests {
+		rule, err := ParseScopeRule(tt.rule)
+		assert.NoError(t, err, tt.rule)
+		assert.Equal(t, tt.want, rule.Matches(tt.target), "%s on %+v", tt.rule, tt.target)
+	}
+
+	for _, invalid := range []string{"regex:(", "cidr:10.0.0.0/99", "label:=x", "glob:"} {
+		_, err := ParseScopeRule(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestScopeApply(t *testing.T) {
+	denylist := filepath.Join(t.TempDir(), "denylist")
+	os.WriteFile(denylist, []byte("# contractually out of scope\n\n203.0.113.0/24\npartner.example.com # until 2027\n"), 0o644)
+
+	scope, err := NewScope(&AppConfig{
+		Include:      "*.example.com,203.0.113.0/24",
+		Exclude:      "regex:^_acme-challenge\\.,label:env=dev",
+		DenylistFile: denylist,
+	})
+	assert.NoError(t, err)
+
+	conf := Config{
+		Domains: []string{"www.example.com", "203.0.113.9", "198.51.100.1"},
+		Targets: []Target{
+			{Host: "_acme-challenge.example.com"},
+			{Host: "partner.example.com"},
+			{Host: "dev.example.com", Labels: map[string]string{"env": "dev"}},
+			{Host: "api.example.com", Address: "203.0.113.10"},
+			{Host: "shop.example.com", Labels: map[string]string{"env": "prod"}},
+		},
+	}
+
+	scope.Apply(&conf)
+
+	assert.Equal(t, []string{"www.example.com"}, conf.Domains)
+	assert.Equal(t, []Target{{Host: "shop.example.com", Labels: map[string]string{"env": "prod"}}}, conf.Targets)
+	assert.Equal(t, []Exclusion{
+		{Host: "203.0.113.9", Reason: "denylist", Rule: "cidr:203.0.113.0/24"},
+		{Host: "198.51.100.1", Reason: "not-included"},
+		{Host: "_acme-challenge.example.com", Reason: "exclude", Rule: "regex:^_acme-challenge\\."},
+		{Host: "partner.example.com", Reason: "denylist", Rule: "glob:partner.example.com"},
+		{Host: "dev.example.com", Reason: "exclude", Rule: "label:env=dev"},
+		{Host: "api.example.com", Address: "203.0.113.10", Reason: "denylist", Rule: "cidr:203.0.113.0/24"},
+	}, scope.Excluded)
+
+	report := filepath.Join(t.TempDir(), "excluded.csv")
+	assert.NoError(t, WriteExclusionReport(report, scope.Excluded))
+	data, _ := os.ReadFile(report)
+	assert.Contains(t, string(data), "Host,Address,Reason,Rule,Count\n203.0.113.9,,denylist,cidr:203.0.113.0/24,1\n")
+}
+
+func TestScopeFilter(t *testing.T) {
+	scope, err := NewScope(&AppConfig{Exclude: "10.0.0.2,10.0.0.5"})
+	assert.NoError(t, err)
+	deny, err := ParseScopeRule("10.0.0.4")
+	assert.NoError(t, err)
+	scope.Deny = []ScopeRule{deny}
+
+	ips, _, err := ExpandCIDR("10.0.0.0/29", CIDROptions{SkipNetworkBroadcast: true})
+	assert.NoError(t, err)
+
+	var got []string
+	for target := range scope.Filter(Config{Cidr: []string{"10.0.0.0/29"}}.Each(CIDROptions{SkipNetworkBroadcast: true})) {
+		got = append(got, target.Host)
+	}
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.3", "10.0.0.6"}, got)
+	assert.Equal(t, []Exclusion{
+		{Host: "cidr", Reason: "exclude", Rule: "cidr:10.0.0.2", Count: 1},
+		{Host: "cidr", Reason: "denylist", Rule: "cidr:10.0.0.4", Count: 1},
+		{Host: "cidr", Reason: "exclude", Rule: "cidr:10.0.0.5", Count: 1},
+	}, scope.Excluded, "streamed addresses are counted per rule")
+
+	// A large range dropped by one rule is a single entry
+	scope, err = NewScope(&AppConfig{Include: "10.1.0.0/16"})
+	assert.NoError(t, err)
+	for range scope.Filter(Config{Cidr: []string{"10.0.0.0/16"}}.Each(CIDROptions{})) {
+	}
+	assert.Equal(t, []Exclusion{{Host: "cidr", Reason: "not-included", Count: 65536}}, scope.Excluded)
+
+	// Stopping early must not walk the rest of the range
+	for ip := range ips {
+		assert.Equal(t, "10.0.0.1", ip)
+		break
+	}
+}
+
+func TestScopeCheckCap(t *testing.T) {
+	scope, err := NewScope(&AppConfig{MaxTargets: 2})
+	assert.NoError(t, err)
+
+	assert.NoError(t, scope.CheckCap(2))
+	assert.ErrorContains(t, scope.CheckCap(3), "3 targets exceed the safety cap of 2")
+	assert.NoError(t, (&Scope{}).CheckCap(1<<40), "0 disables the cap")
+}
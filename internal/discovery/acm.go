@@ -0,0 +1,182 @@
+package discovery
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/andre/ssl-cert-test/internal/config"
+	"github.com/andre/ssl-cert-test/internal/scan"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/acm"
+	"github.com/aws/aws-sdk-go/service/iam"
+)
+
+// AWSCertOptions selects the regions and accounts whose ACM and IAM certificates are inventoried
+type AWSCertOptions struct {
+	Regions  []string    // ACM is regional; defaults to the session region
+	Accounts AWSAccounts // When a role is set, inventory every account instead of the current one
+}
+
+// FetchAWSCertificates inventories ACM certificates in every region and IAM server
+// certificates as result rows, without a handshake. ACM certificates stuck in
+// PENDING_VALIDATION or not eligible for renewal are flagged with an error.
+func FetchAWSCertificates(opts AWSCertOptions, now time.Time) ([]config.DomainValidity, error) {
+	if opts.Accounts.RoleName == "" {
+		sess, err := newAWSSession()
+		if err != nil {
+			return nil, err
+		}
+		return awsAccountCertificates(sess, opts.Regions, now)
+	}
+
+	var results []config.DomainValidity
+	failures, err := forEachAWSAccount(opts.Accounts, "acm", func(accountID string, sess *session.Session) error {
+		found, err := awsAccountCertificates(sess, opts.Regions, now)
+		if err != nil {
+			return err
+		}
+		for _, r := range found {
+			r.Labels["aws_account"] = accountID
+			results = append(results, r)
+		}
+		return nil
+	})
+
+	return append(results, failures...), err
+}
+
+func awsAccountCertificates(sess *session.Session, regions []string, now time.Time) ([]config.DomainValidity, error) {
+	regions, err := awsRegions(sess, regions)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []config.DomainValidity
+	for _, region := range regions {
+		found, err := listACMCertificates(acm.New(sess, aws.NewConfig().WithRegion(region)), region, now)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, found...)
+	}
+
+	// IAM is global, so its server certificates are listed once per account
+	found, err := listIAMServerCertificates(iam.New(sess), now)
+	if err != nil {
+		return nil, err
+	}
+	return append(results, found...), nil
+}
+
+// listACMCertificates describes every certificate of the region, whatever its key type
+func listACMCertificates(svc *acm.ACM, region string, now time.Time) ([]config.DomainValidity, error) {
+	var arns []*string
+	input := &acm.ListCertificatesInput{
+		// Without a key type filter ACM only returns RSA certificates
+		Includes: &acm.Filters{KeyTypes: aws.StringSlice(acm.KeyAlgorithm_Values())},
+	}
+	err := svc.ListCertificatesPages(input, func(page *acm.ListCertificatesOutput, lastPage bool) bool {
+		for _, summary := range page.CertificateSummaryList {
+			arns = append(arns, summary.CertificateArn)
+		}
+		return !lastPage
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ACM certificates in %s: %v", region, err)
+	}
+
+	var results []config.DomainValidity
+	for _, arn := range arns {
+		out, err := svc.DescribeCertificate(&acm.DescribeCertificateInput{CertificateArn: arn})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe ACM certificate %s: %v", aws.StringValue(arn), err)
+		}
+		results = append(results, acmResult(out.Certificate, region, now))
+	}
+	return results, nil
+}
+
+func acmResult(cert *acm.CertificateDetail, region string, now time.Time) config.DomainValidity {
+	status := aws.StringValue(cert.Status)
+	eligibility := aws.StringValue(cert.RenewalEligibility)
+
+	var problems []string
+	if status == acm.CertificateStatusPendingValidation {
+		problems = append(problems, "certificate stuck in PENDING_VALIDATION")
+	} else if status != acm.CertificateStatusIssued {
+		problems = append(problems, fmt.Sprintf("acm certificate status: %s", status))
+	}
+	if eligibility == acm.RenewalEligibilityIneligible {
+		problems = append(problems, "not eligible for managed renewal")
+	}
+
+	result := config.DomainValidity{
+		Domain:          aws.StringValue(cert.DomainName),
+		Serial:          aws.StringValue(cert.Serial),
+		Issuer:          aws.StringValue(cert.Issuer),
+		SignatureAlgo:   aws.StringValue(cert.SignatureAlgorithm),
+		SANs:            aws.StringValueSlice(cert.SubjectAlternativeNames),
+		NotBefore:       aws.TimeValue(cert.NotBefore),
+		NotAfter:        aws.TimeValue(cert.NotAfter),
+		DaysUntilExpiry: 999999,
+		ChainStatus:     status,
+		Error:           strings.Join(problems, "; "),
+		Source:          "acm",
+		Labels: map[string]string{
+			"aws_region":  region,
+			"acm_arn":     aws.StringValue(cert.CertificateArn),
+			"acm_type":    aws.StringValue(cert.Type),
+			"acm_renewal": eligibility,
+			"acm_in_use":  fmt.Sprint(len(cert.InUseBy) > 0),
+		},
+	}
+	if cert.NotAfter != nil {
+		result.DaysUntilExpiry = scan.DaysUntil(*cert.NotAfter, now)
+	}
+	if len(cert.InUseBy) > 0 {
+		result.Labels["acm_in_use_by"] = strings.Join(aws.StringValueSlice(cert.InUseBy), ",")
+	}
+	return result
+}
+
+// listIAMServerCertificates returns IAM server certificates, which never renew on their own
+func listIAMServerCertificates(svc *iam.IAM, now time.Time) ([]config.DomainValidity, error) {
+	var metas []*iam.ServerCertificateMetadata
+	err := svc.ListServerCertificatesPages(&iam.ListServerCertificatesInput{}, func(page *iam.ListServerCertificatesOutput, lastPage bool) bool {
+		metas = append(metas, page.ServerCertificateMetadataList...)
+		return !lastPage
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list IAM server certificates: %v", err)
+	}
+
+	var results []config.DomainValidity
+	for _, meta := range metas {
+		name := aws.StringValue(meta.ServerCertificateName)
+		out, err := svc.GetServerCertificate(&iam.GetServerCertificateInput{ServerCertificateName: meta.ServerCertificateName})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get IAM server certificate %s: %v", name, err)
+		}
+
+		var result config.DomainValidity
+		if certs, err := scan.ParsePEMCertificates([]byte(aws.StringValue(out.ServerCertificate.CertificateBody))); err == nil {
+			result = scan.CertificateResult("iam", "", certs[0], now)
+		} else {
+			result = config.DomainValidity{
+				Domain:          name,
+				NotAfter:        aws.TimeValue(meta.Expiration),
+				DaysUntilExpiry: scan.DaysUntil(aws.TimeValue(meta.Expiration), now),
+				Source:          "iam",
+			}
+		}
+		result.ChainStatus = "IAM server certificate (no managed renewal)"
+		result.Labels = map[string]string{
+			"iam_cert_name": name,
+			"iam_arn":       aws.StringValue(meta.Arn),
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,135 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var signingRegionRe = regexp.MustCompile(`Credential=[^/]+/\d+/([^/]+)/`)
+
+// fakeAWSCerts serves ACM (JSON-RPC) and IAM (query protocol) calls
+type fakeAWSCerts struct {
+	t       *testing.T
+	now     time.Time
+	iamPEM  string
+	regions map[string][]map[string]interface{} // region -> certificate details
+}
+
+func (f *fakeAWSCerts) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	region := ""
+	if m := signingRegionRe.FindStringSubmatch(r.Header.Get("Authorization")); len(m) == 2 {
+		region = m[1]
+	}
+
+	switch target := r.Header.Get("X-Amz-Target"); target {
+	case "CertificateManager.ListCertificates":
+		var req struct {
+			Includes struct{ KeyTypes []string }
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		assert.Contains(f.t, req.Includes.KeyTypes, "EC_prime256v1", "ECDSA certificates must be listed too")
+
+		var summaries []map[string]string
+		for _, c := range f.regions[region] {
+			summaries = append(summaries, map[string]string{"CertificateArn": c["CertificateArn"].(string)})
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		json.NewEncoder(w).Encode(map[string]interface{}{"CertificateSummaryList": summaries})
+		return
+	case "CertificateManager.DescribeCertificate":
+		var req struct{ CertificateArn string }
+		json.NewDecoder(r.Body).Decode(&req)
+		for _, c := range f.regions[region] {
+			if c["CertificateArn"] == req.CertificateArn {
+				w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+				json.NewEncoder(w).Encode(map[string]interface{}{"Certificate": c})
+				return
+			}
+		}
+		f.t.Errorf("unknown certificate %s in %s", req.CertificateArn, region)
+		http.NotFound(w, r)
+		return
+	}
+
+	r.ParseForm()
+	w.Header().Set("Content-Type", "text/xml")
+	switch r.Form.Get("Action") {
+	case "ListServerCertificates":
+		fmt.Fprintf(w, `<ListServerCertificatesResponse><ListServerCertificatesResult><IsTruncated>false</IsTruncated><ServerCertificateMetadataList><member><ServerCertificateName>legacy-elb</ServerCertificateName><ServerCertificateId>ID1</ServerCertificateId><Path>/</Path><Arn>arn:aws:iam::123456789012:server-certificate/legacy-elb</Arn><Expiration>%s</Expiration></member></ServerCertificateMetadataList></ListServerCertificatesResult></ListServerCertificatesResponse>`,
+			f.now.Add(5*24*time.Hour).UTC().Format(time.RFC3339))
+	case "GetServerCertificate":
+		fmt.Fprintf(w, `<GetServerCertificateResponse><GetServerCertificateResult><ServerCertificate><ServerCertificateMetadata><ServerCertificateName>legacy-elb</ServerCertificateName><ServerCertificateId>ID1</ServerCertificateId><Path>/</Path><Arn>arn:aws:iam::123456789012:server-certificate/legacy-elb</Arn></ServerCertificateMetadata><CertificateBody>%s</CertificateBody></ServerCertificate></GetServerCertificateResult></GetServerCertificateResponse>`,
+			f.iamPEM)
+	default:
+		f.t.Errorf("unexpected AWS call: %s %s", r.Header.Get("X-Amz-Target"), r.Form.Get("Action"))
+		http.NotFound(w, r)
+	}
+}
+
+func TestFetchAWSCertificates(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	epoch := func(d time.Duration) int64 { return now.Add(d).Unix() }
+
+	useFakeAWS(t, &fakeAWSCerts{
+		t:      t,
+		now:    now,
+		iamPEM: string(testCertPEM(t, "legacy.example.com", []string{"legacy.example.com"}, now.Add(5*24*time.Hour))),
+		regions: map[string][]map[string]interface{}{
+			"us-east-1": {
+				{
+					"CertificateArn": "arn:aws:acm:us-east-1:123456789012:certificate/cdn", "DomainName": "cdn.example.com",
+					"SubjectAlternativeNames": []string{"cdn.example.com"}, "Status": "ISSUED", "Type": "AMAZON_ISSUED",
+					"RenewalEligibility": "ELIGIBLE", "Issuer": "Amazon", "NotAfter": epoch(60 * 24 * time.Hour),
+					"InUseBy": []string{"arn:aws:cloudfront::123456789012:distribution/E1"},
+				},
+				{
+					"CertificateArn": "arn:aws:acm:us-east-1:123456789012:certificate/new", "DomainName": "new.example.com",
+					"Status": "PENDING_VALIDATION", "Type": "AMAZON_ISSUED", "RenewalEligibility": "INELIGIBLE",
+				},
+			},
+			"eu-west-1": {
+				{
+					"CertificateArn": "arn:aws:acm:eu-west-1:123456789012:certificate/imported", "DomainName": "api.example.com",
+					"Status": "ISSUED", "Type": "IMPORTED", "RenewalEligibility": "INELIGIBLE", "NotAfter": epoch(20 * 24 * time.Hour),
+					"InUseBy": []string{"arn:aws:elasticloadbalancing:eu-west-1:123456789012:loadbalancer/app/api/1"},
+				},
+			},
+		},
+	})
+
+	results, err := FetchAWSCertificates(AWSCertOptions{Regions: []string{"us-east-1", "eu-west-1"}}, now)
+
+	assert.NoError(t, err)
+	assert.Len(t, results, 4)
+
+	cdn := results[0]
+	assert.Equal(t, "acm", cdn.Source)
+	assert.Equal(t, "cdn.example.com", cdn.Domain)
+	assert.Equal(t, 60, cdn.DaysUntilExpiry)
+	assert.Empty(t, cdn.Error)
+	assert.Equal(t, "us-east-1", cdn.Labels["aws_region"])
+	assert.Equal(t, "arn:aws:cloudfront::123456789012:distribution/E1", cdn.Labels["acm_in_use_by"])
+
+	pending := results[1]
+	assert.Equal(t, 999999, pending.DaysUntilExpiry)
+	assert.Contains(t, pending.Error, "PENDING_VALIDATION")
+
+	imported := results[2]
+	assert.Equal(t, "eu-west-1", imported.Labels["aws_region"])
+	assert.Equal(t, 20, imported.DaysUntilExpiry)
+	assert.Contains(t, imported.Error, "not eligible for managed renewal")
+
+	legacy := results[3]
+	assert.Equal(t, "iam", legacy.Source)
+	assert.Equal(t, "legacy.example.com", legacy.Domain)
+	assert.Equal(t, 5, legacy.DaysUntilExpiry)
+	assert.Equal(t, "legacy-elb", legacy.Labels["iam_cert_name"])
+	assert.True(t, strings.HasPrefix(legacy.ChainStatus, "IAM server certificate"))
+}
@@ -0,0 +1,121 @@
+package discovery
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/andre/ssl-cert-test/internal/config"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/organizations"
+)
+
+// Exported variable to allow pointing every AWS client at a local endpoint in tests
+var AWSEndpointURL = ""
+
+// AWSAccounts selects the accounts visited by assuming RoleName in each of them
+type AWSAccounts struct {
+	IDs              []string
+	UseOrganizations bool // Add every active account of the AWS Organization
+	RoleName         string
+}
+
+// newAWSSession builds a session from the default credential chain and shared config
+func newAWSSession() (*session.Session, error) {
+	opts := session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}
+	if AWSEndpointURL != "" {
+		opts.Config.Endpoint = aws.String(AWSEndpointURL)
+	}
+
+	sess, err := session.NewSessionWithOptions(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %v", err)
+	}
+	return sess, nil
+}
+
+// awsRegions returns the requested regions, or the session region when none are set
+func awsRegions(sess *session.Session, regions []string) ([]string, error) {
+	if len(regions) > 0 {
+		return regions, nil
+	}
+	region := aws.StringValue(sess.Config.Region)
+	if region == "" {
+		return nil, fmt.Errorf("no AWS region configured")
+	}
+	return []string{region}, nil
+}
+
+// forEachAWSAccount assumes the role in every selected account and calls fn with
+// the resulting session. Accounts that fail are logged and returned as error rows
+// so one broken account does not abort the whole run.
+func forEachAWSAccount(accounts AWSAccounts, source string, fn func(accountID string, sess *session.Session) error) ([]config.DomainValidity, error) {
+	base, err := newAWSSession()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := accounts.IDs
+	if accounts.UseOrganizations {
+		orgIDs, err := listOrganizationAccounts(base)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, orgIDs...)
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no AWS accounts configured")
+	}
+
+	var failures []config.DomainValidity
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		err := assumeAndRun(base, id, accounts.RoleName, fn)
+		if err != nil {
+			slog.Warn("aws account discovery failed", "account", id, "error", err)
+			failures = append(failures, discoveryError(source, "aws:"+id, map[string]string{"aws_account": id}, err))
+		}
+	}
+	return failures, nil
+}
+
+func assumeAndRun(base *session.Session, accountID, roleName string, fn func(string, *session.Session) error) error {
+	roleARN := fmt.Sprintf("arn:aws:iam::%s:role/%s", accountID, roleName)
+	creds := stscreds.NewCredentials(base, roleARN, func(p *stscreds.AssumeRoleProvider) {
+		p.RoleSessionName = "ssl-cert-checker"
+	})
+
+	// Resolve the credentials up front so a denied AssumeRole gets a clear error
+	if _, err := creds.Get(); err != nil {
+		return fmt.Errorf("failed to assume role %s: %v", roleARN, err)
+	}
+
+	return fn(accountID, base.Copy(&aws.Config{Credentials: creds}))
+}
+
+// listOrganizationAccounts returns the IDs of every active account in the organization
+func listOrganizationAccounts(sess *session.Session) ([]string, error) {
+	svc := organizations.New(sess)
+
+	var ids []string
+	err := svc.ListAccountsPages(&organizations.ListAccountsInput{}, func(page *organizations.ListAccountsOutput, lastPage bool) bool {
+		for _, acct := range page.Accounts {
+			if aws.StringValue(acct.Status) == organizations.AccountStatusActive {
+				ids = append(ids, aws.StringValue(acct.Id))
+			}
+		}
+		return !lastPage
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organization accounts: %v", err)
+	}
+	return ids, nil
+}
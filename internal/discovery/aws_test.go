@@ -0,0 +1,118 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/stretchr/testify/assert"
+)
+
+// useFakeAWS points every AWS client at the handler with static test credentials
+func useFakeAWS(t *testing.T, h http.Handler) {
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_CONFIG_FILE", "/dev/null")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/dev/null")
+
+	originalURL := AWSEndpointURL
+	AWSEndpointURL = ts.URL
+	t.Cleanup(func() { AWSEndpointURL = originalURL })
+}
+
+// fakeAWS routes query-protocol calls (STS), JSON-RPC calls (Organizations) and
+// everything else (REST services) to the matching fake handler
+type fakeAWS struct {
+	denied   map[string]bool // account IDs whose AssumeRole is refused
+	accounts []string        // accounts returned by Organizations ListAccounts
+	rest     http.Handler
+}
+
+var accessKeyRe = regexp.MustCompile(`Credential=([^/]+)/`)
+
+// callerAccount returns the account a request was signed for by an assumed role
+func callerAccount(r *http.Request) string {
+	m := accessKeyRe.FindStringSubmatch(r.Header.Get("Authorization"))
+	if len(m) < 2 {
+		return ""
+	}
+	return strings.TrimPrefix(m[1], "ASSUMED-")
+}
+
+func (f *fakeAWS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if target := r.Header.Get("X-Amz-Target"); strings.HasPrefix(target, "AWSOrganizations") {
+		var accounts []map[string]string
+		for _, id := range f.accounts {
+			accounts = append(accounts, map[string]string{"Id": id, "Status": "ACTIVE"})
+		}
+		accounts = append(accounts, map[string]string{"Id": "999999999999", "Status": "SUSPENDED"})
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		json.NewEncoder(w).Encode(map[string]interface{}{"Accounts": accounts})
+		return
+	}
+
+	if r.Method == http.MethodPost && r.URL.Path == "/" {
+		r.ParseForm()
+		if r.Form.Get("Action") == "AssumeRole" {
+			f.assumeRole(w, r.Form.Get("RoleArn"))
+			return
+		}
+	}
+
+	f.rest.ServeHTTP(w, r)
+}
+
+func (f *fakeAWS) assumeRole(w http.ResponseWriter, roleARN string) {
+	account := strings.Split(roleARN, ":")[4]
+	w.Header().Set("Content-Type", "text/xml")
+	if f.denied[account] {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `<ErrorResponse><Error><Type>Sender</Type><Code>AccessDenied</Code><Message>not authorized</Message></Error></ErrorResponse>`)
+		return
+	}
+	fmt.Fprintf(w, `<AssumeRoleResponse><AssumeRoleResult><Credentials><AccessKeyId>ASSUMED-%s</AccessKeyId><SecretAccessKey>secret</SecretAccessKey><SessionToken>token</SessionToken><Expiration>2099-01-01T00:00:00Z</Expiration></Credentials><AssumedRoleUser><Arn>%s</Arn><AssumedRoleId>id</AssumedRoleId></AssumedRoleUser></AssumeRoleResult></AssumeRoleResponse>`, account, roleARN)
+}
+
+func TestForEachAWSAccount(t *testing.T) {
+	useFakeAWS(t, &fakeAWS{
+		denied:   map[string]bool{"222222222222": true},
+		accounts: []string{"333333333333", "111111111111"},
+		rest:     http.NotFoundHandler(),
+	})
+
+	var visited []string
+	failures, err := forEachAWSAccount(AWSAccounts{
+		IDs:              []string{"111111111111", "222222222222"},
+		UseOrganizations: true,
+		RoleName:         "Auditor",
+	}, "test", func(accountID string, sess *session.Session) error {
+		visited = append(visited, accountID)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	// Duplicates from the organization listing are visited once, suspended accounts never
+	assert.Equal(t, []string{"111111111111", "333333333333"}, visited)
+	assert.Len(t, failures, 1)
+	assert.Equal(t, "aws:222222222222", failures[0].Domain)
+	assert.Equal(t, "222222222222", failures[0].Labels["aws_account"])
+	assert.Contains(t, failures[0].Error, "failed to assume role")
+}
+
+func TestForEachAWSAccount_NoAccounts(t *testing.T) {
+	useFakeAWS(t, &fakeAWS{rest: http.NotFoundHandler()})
+
+	_, err := forEachAWSAccount(AWSAccounts{RoleName: "Auditor"}, "test", func(string, *session.Session) error {
+		return nil
+	})
+	assert.Error(t, err)
+}
@@ -3,9 +3,15 @@ package discovery
 import (
 	"context"
 	"fmt"
+	"log/slog"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime" // <--- Added Import
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/privatedns/armprivatedns"
+	"github.com/andre/ssl-cert-test/internal/config"
 )
 
 // AzurePagingClient interface allows us to mock the paginator in tests
@@ -28,11 +34,29 @@ func (p *RealAzurePager) NextPage(ctx context.Context) (armdns.RecordSetsClientL
 	return p.pager.NextPage(ctx)
 }
 
+// azureCredential uses the client secret when one is configured and falls back to
+// DefaultAzureCredential (managed identity, workload identity, Azure CLI, ...)
+func azureCredential(cID, cSecret, tID string) (azcore.TokenCredential, error) {
+	if cID != "" && cSecret != "" && tID != "" {
+		cred, err := azidentity.NewClientSecretCredential(tID, cID, cSecret, nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid azure credentials: %w", err)
+		}
+		return cred, nil
+	}
+
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create default azure credential: %w", err)
+	}
+	return cred, nil
+}
+
 // AzureClientCreatorFunc is a variable we can swap in tests to return a mock client
 var AzureClientCreatorFunc = func(subID, rg, zone, cID, cSecret, tID string) (AzurePagingClient, error) {
-	cred, err := azidentity.NewClientSecretCredential(tID, cID, cSecret, nil)
+	cred, err := azureCredential(cID, cSecret, tID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid azure credentials: %w", err)
+		return nil, err
 	}
 
 	client, err := armdns.NewRecordSetsClient(subID, cred, nil)
@@ -45,10 +69,11 @@ var AzureClientCreatorFunc = func(subID, rg, zone, cID, cSecret, tID string) (Az
 	return &RealAzurePager{pager: pager}, nil
 }
 
-// FetchDomainsFromAzure retrieves A and CNAME records from an Azure DNS Zone
+// FetchDomainsFromAzure retrieves A, AAAA and CNAME records from an Azure DNS Zone.
+// Without a client secret it authenticates through DefaultAzureCredential.
 func FetchDomainsFromAzure(subID, rg, zone, cID, cSecret, tID string) ([]string, error) {
-	if subID == "" || rg == "" || zone == "" || cID == "" || cSecret == "" || tID == "" {
-		return nil, fmt.Errorf("missing required azure configuration fields")
+	if subID == "" || rg == "" || zone == "" {
+		return nil, fmt.Errorf("azure subscription, resource group and zone are required")
 	}
 
 	// Use the creator function (which might be real or mocked)
@@ -66,24 +91,215 @@ func FetchDomainsFromAzure(subID, rg, zone, cID, cSecret, tID string) ([]string,
 			return nil, fmt.Errorf("failed to list dns records: %w", err)
 		}
 
-		for _, record := range page.Value {
-			// In Azure SDK, record.Name is relative (e.g., "www"), not FQDN
-			// We construct the FQDN manually.
-
-			// Simple check for A or CNAME presence in the struct properties
-			isA := record.Properties.ARecords != nil && len(record.Properties.ARecords) > 0
-			isCNAME := record.Properties.CnameRecord != nil
-
-			if isA || isCNAME {
-				name := *record.Name
-				if name == "@" {
-					domains = append(domains, zone)
-				} else {
-					domains = append(domains, fmt.Sprintf("%s.%s", name, zone))
-				}
+		domains = append(domains, azureRecordNames(page.Value, zone, azurePublicAddressRecord)...)
+	}
+
+	return domains, nil
+}
+
+// azureRecordNames returns the FQDNs of the A, AAAA and CNAME record sets of
+// a zone; address reads a record set of the API the zone came from
+func azureRecordNames[R any](records []R, zone string, address func(R) (string, bool)) []string {
+	var names []string
+	for _, record := range records {
+		// In Azure SDK, record.Name is relative (e.g., "www"), not FQDN
+		if name, ok := address(record); ok {
+			names = append(names, azureFQDN(name, zone))
+		}
+	}
+	return names
+}
+
+// azurePublicAddressRecord returns the relative name of a dnszones A, AAAA or CNAME record set
+func azurePublicAddressRecord(r *armdns.RecordSet) (string, bool) {
+	if r == nil || r.Name == nil || r.Properties == nil {
+		return "", false
+	}
+	props := r.Properties
+	return *r.Name, len(props.ARecords) > 0 || len(props.AaaaRecords) > 0 || props.CnameRecord != nil
+}
+
+// azurePrivateAddressRecord returns the relative name of a privateDnsZones A, AAAA or CNAME record set
+func azurePrivateAddressRecord(r *armprivatedns.RecordSet) (string, bool) {
+	if r == nil || r.Name == nil || r.Properties == nil {
+		return "", false
+	}
+	props := r.Properties
+	return *r.Name, len(props.ARecords) > 0 || len(props.AaaaRecords) > 0 || props.CnameRecord != nil
+}
+
+// azureFQDN builds the FQDN from the relative record name Azure returns (e.g. "www" or "@")
+func azureFQDN(name, zone string) string {
+	if name == "@" {
+		return zone
+	}
+	return fmt.Sprintf("%s.%s", name, zone)
+}
+
+// AzureZone is a public or private DNS zone found in a subscription.
+// PrivateDNS tells which API lists it: legacy dnszones zones can be private
+// too, but their records are only served by the dnszones API.
+type AzureZone struct {
+	SubscriptionID string
+	ResourceGroup  string
+	Name           string
+	Private        bool
+	PrivateDNS     bool
+}
+
+// AzureDNSClient enumerates the zones of one subscription and the A/AAAA/CNAME
+// names inside them, hiding the split between public and private DNS APIs
+type AzureDNSClient interface {
+	ListZones(ctx context.Context) ([]AzureZone, error)
+	ListRecordNames(ctx context.Context, zone AzureZone) ([]string, error)
+}
+
+// AzureDNSClientCreatorFunc is a variable we can swap in tests to return a mock client
+var AzureDNSClientCreatorFunc = func(subID, cID, cSecret, tID string) (AzureDNSClient, error) {
+	cred, err := azureCredential(cID, cSecret, tID)
+	if err != nil {
+		return nil, err
+	}
+
+	public, err := armdns.NewClientFactory(subID, cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create azure dns client: %w", err)
+	}
+	private, err := armprivatedns.NewClientFactory(subID, cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create azure private dns client: %w", err)
+	}
+
+	return &realAzureDNSClient{subID: subID, public: public, private: private}, nil
+}
+
+// FetchTargetsFromAzureSubscriptions walks every public and private DNS zone of the
+// given subscriptions and tags each name with its subscription, resource group and
+// zone. Subscriptions that fail are returned as error rows instead of aborting.
+func FetchTargetsFromAzureSubscriptions(subIDs []string, cID, cSecret, tID string) ([]config.Target, []config.DomainValidity, error) {
+	if len(subIDs) == 0 {
+		return nil, nil, fmt.Errorf("at least one azure subscription is required")
+	}
+
+	var targets []config.Target
+	var failures []config.DomainValidity
+	ctx := context.Background()
+
+	for _, subID := range subIDs {
+		found, err := azureSubscriptionTargets(ctx, subID, cID, cSecret, tID)
+		if err != nil {
+			slog.Warn("azure subscription discovery failed", "subscription", subID, "error", err)
+			failures = append(failures, discoveryError("azure", "azure:"+subID, map[string]string{"azure_subscription": subID}, err))
+			continue
+		}
+		targets = append(targets, found...)
+	}
+
+	return targets, failures, nil
+}
+
+func azureSubscriptionTargets(ctx context.Context, subID, cID, cSecret, tID string) ([]config.Target, error) {
+	client, err := AzureDNSClientCreatorFunc(subID, cID, cSecret, tID)
+	if err != nil {
+		return nil, err
+	}
+
+	zones, err := client.ListZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []config.Target
+	for _, zone := range zones {
+		names, err := client.ListRecordNames(ctx, zone)
+		if err != nil {
+			return nil, fmt.Errorf("zone %s: %w", zone.Name, err)
+		}
+		for _, name := range names {
+			targets = append(targets, config.Target{
+				Host: name,
+				Labels: map[string]string{
+					"azure_subscription":   zone.SubscriptionID,
+					"azure_resource_group": zone.ResourceGroup,
+					"azure_zone":           zone.Name,
+					"azure_private":        fmt.Sprint(zone.Private),
+				},
+			})
+		}
+	}
+	return targets, nil
+}
+
+// realAzureDNSClient talks to the Microsoft.Network dnszones and privateDnsZones APIs
+type realAzureDNSClient struct {
+	subID   string
+	public  *armdns.ClientFactory
+	private *armprivatedns.ClientFactory
+}
+
+func (c *realAzureDNSClient) ListZones(ctx context.Context) ([]AzureZone, error) {
+	var zones []AzureZone
+	add := func(id, name *string, private, privateDNS bool) error {
+		rid, err := arm.ParseResourceID(*id)
+		if err != nil {
+			return fmt.Errorf("invalid zone resource id %s: %w", *id, err)
+		}
+		zones = append(zones, AzureZone{SubscriptionID: c.subID, ResourceGroup: rid.ResourceGroupName, Name: *name, Private: private, PrivateDNS: privateDNS})
+		return nil
+	}
+
+	publicPager := c.public.NewZonesClient().NewListPager(nil)
+	for publicPager.More() {
+		page, err := publicPager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list dns zones: %w", err)
+		}
+		for _, z := range page.Value {
+			private := z.Properties != nil && z.Properties.ZoneType != nil && *z.Properties.ZoneType == armdns.ZoneTypePrivate
+			if err := add(z.ID, z.Name, private, false); err != nil {
+				return nil, err
 			}
 		}
 	}
 
-	return domains, nil
+	privatePager := c.private.NewPrivateZonesClient().NewListPager(nil)
+	for privatePager.More() {
+		page, err := privatePager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list private dns zones: %w", err)
+		}
+		for _, z := range page.Value {
+			if err := add(z.ID, z.Name, true, true); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return zones, nil
+}
+
+func (c *realAzureDNSClient) ListRecordNames(ctx context.Context, zone AzureZone) ([]string, error) {
+	var names []string
+
+	if !zone.PrivateDNS {
+		pager := &RealAzurePager{pager: c.public.NewRecordSetsClient().NewListByDNSZonePager(zone.ResourceGroup, zone.Name, nil)}
+		for pager.More() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list dns records: %w", err)
+			}
+			names = append(names, azureRecordNames(page.Value, zone.Name, azurePublicAddressRecord)...)
+		}
+		return names, nil
+	}
+
+	pager := c.private.NewRecordSetsClient().NewListPager(zone.ResourceGroup, zone.Name, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list private dns records: %w", err)
+		}
+		names = append(names, azureRecordNames(page.Value, zone.Name, azurePrivateAddressRecord)...)
+	}
+	return names, nil
 }
@@ -2,9 +2,17 @@ package discovery
 
 import (
 	"context"
+	"errors"
+	"net/http"
 	"testing"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns"
+	dnsfake "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/privatedns/armprivatedns"
+	privatednsfake "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/privatedns/armprivatedns/fake"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -88,3 +96,130 @@ func TestFetchDomainsFromAzure_MissingArgs(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "required")
 }
+
+// MockAzureDNSClient implements AzureDNSClient
+type MockAzureDNSClient struct {
+	zones   []AzureZone
+	records map[string][]string
+	err     error
+}
+
+func (m *MockAzureDNSClient) ListZones(ctx context.Context) ([]AzureZone, error) {
+	return m.zones, m.err
+}
+
+func (m *MockAzureDNSClient) ListRecordNames(ctx context.Context, zone AzureZone) ([]string, error) {
+	return m.records[zone.Name], nil
+}
+
+func TestFetchTargetsFromAzureSubscriptions(t *testing.T) {
+	originalCreator := AzureDNSClientCreatorFunc
+	AzureDNSClientCreatorFunc = func(subID, cID, cSecret, tID string) (AzureDNSClient, error) {
+		if subID == "sub-broken" {
+			return &MockAzureDNSClient{err: errors.New("authorization failed")}, nil
+		}
+		return &MockAzureDNSClient{
+			zones: []AzureZone{
+				{SubscriptionID: subID, ResourceGroup: "rg-web", Name: "example.com"},
+				{SubscriptionID: subID, ResourceGroup: "rg-core", Name: "internal.example", Private: true, PrivateDNS: true},
+			},
+			records: map[string][]string{
+				"example.com":      {"www.example.com", "example.com"},
+				"internal.example": {"db.internal.example"},
+			},
+		}, nil
+	}
+	defer func() { AzureDNSClientCreatorFunc = originalCreator }()
+
+	targets, failures, err := FetchTargetsFromAzureSubscriptions([]string{"sub-a", "sub-broken"}, "", "", "")
+
+	assert.NoError(t, err)
+	assert.Len(t, targets, 3)
+	assert.Equal(t, "www.example.com", targets[0].Host)
+	assert.Equal(t, map[string]string{
+		"azure_subscription":   "sub-a",
+		"azure_resource_group": "rg-web",
+		"azure_zone":           "example.com",
+		"azure_private":        "false",
+	}, targets[0].Labels)
+	assert.Equal(t, "db.internal.example", targets[2].Host)
+	assert.Equal(t, "true", targets[2].Labels["azure_private"])
+
+	assert.Len(t, failures, 1)
+	assert.Equal(t, "azure:sub-broken", failures[0].Domain)
+	assert.Equal(t, "azure", failures[0].Source)
+	assert.Contains(t, failures[0].Error, "authorization failed")
+}
+
+func TestAzureRecordNames_Private(t *testing.T) {
+	strPtr := func(s string) *string { return &s }
+	names := azureRecordNames([]*armprivatedns.RecordSet{
+		{Name: strPtr("@"), Properties: &armprivatedns.RecordSetProperties{ARecords: []*armprivatedns.ARecord{{IPv4Address: strPtr("10.0.0.4")}}}},
+		{Name: strPtr("db"), Properties: &armprivatedns.RecordSetProperties{CnameRecord: &armprivatedns.CnameRecord{Cname: strPtr("db01.internal.example")}}},
+		{Name: strPtr("_sip._tcp"), Properties: &armprivatedns.RecordSetProperties{SrvRecords: []*armprivatedns.SrvRecord{{Target: strPtr("sip.internal.example")}}}},
+		{Name: strPtr("broken")},
+	}, "internal.example", azurePrivateAddressRecord)
+
+	assert.Equal(t, []string{"internal.example", "db.internal.example"}, names)
+}
+
+func TestFetchTargetsFromAzureSubscriptions_MissingArgs(t *testing.T) {
+	_, _, err := FetchTargetsFromAzureSubscriptions(nil, "", "", "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "required")
+}
+
+func TestRealAzureDNSClient_LegacyPrivateZone(t *testing.T) {
+	strPtr := func(s string) *string { return &s }
+	zoneType := armdns.ZoneTypePrivate
+
+	publicSrv := dnsfake.ServerFactory{
+		ZonesServer: dnsfake.ZonesServer{
+			NewListPager: func(*armdns.ZonesClientListOptions) (resp azfake.PagerResponder[armdns.ZonesClientListResponse]) {
+				resp.AddPage(http.StatusOK, armdns.ZonesClientListResponse{ZoneListResult: armdns.ZoneListResult{Value: []*armdns.Zone{{
+					ID:         strPtr("/subscriptions/sub/resourceGroups/rg-legacy/providers/Microsoft.Network/dnszones/corp.example"),
+					Name:       strPtr("corp.example"),
+					Properties: &armdns.ZoneProperties{ZoneType: &zoneType},
+				}}}}, nil)
+				return
+			},
+		},
+		RecordSetsServer: dnsfake.RecordSetsServer{
+			NewListByDNSZonePager: func(rg, zone string, _ *armdns.RecordSetsClientListByDNSZoneOptions) (resp azfake.PagerResponder[armdns.RecordSetsClientListByDNSZoneResponse]) {
+				assert.Equal(t, "rg-legacy", rg)
+				resp.AddPage(http.StatusOK, armdns.RecordSetsClientListByDNSZoneResponse{RecordSetListResult: armdns.RecordSetListResult{Value: []*armdns.RecordSet{
+					{Name: strPtr("intranet"), Properties: &armdns.RecordSetProperties{ARecords: []*armdns.ARecord{{IPv4Address: strPtr("10.1.0.4")}}}},
+				}}}, nil)
+				return
+			},
+		},
+	}
+	privateSrv := privatednsfake.ServerFactory{
+		PrivateZonesServer: privatednsfake.PrivateZonesServer{
+			NewListPager: func(*armprivatedns.PrivateZonesClientListOptions) (resp azfake.PagerResponder[armprivatedns.PrivateZonesClientListResponse]) {
+				resp.AddPage(http.StatusOK, armprivatedns.PrivateZonesClientListResponse{}, nil)
+				return
+			},
+		},
+		RecordSetsServer: privatednsfake.RecordSetsServer{
+			NewListPager: func(rg, zone string, _ *armprivatedns.RecordSetsClientListOptions) (resp azfake.PagerResponder[armprivatedns.RecordSetsClientListResponse]) {
+				resp.AddResponseError(http.StatusNotFound, "ResourceNotFound")
+				return
+			},
+		},
+	}
+
+	public, err := armdns.NewClientFactory("sub", &azfake.TokenCredential{}, &arm.ClientOptions{ClientOptions: azcore.ClientOptions{Transport: dnsfake.NewServerFactoryTransport(&publicSrv)}})
+	assert.NoError(t, err)
+	private, err := armprivatedns.NewClientFactory("sub", &azfake.TokenCredential{}, &arm.ClientOptions{ClientOptions: azcore.ClientOptions{Transport: privatednsfake.NewServerFactoryTransport(&privateSrv)}})
+	assert.NoError(t, err)
+	client := &realAzureDNSClient{subID: "sub", public: public, private: private}
+
+	zones, err := client.ListZones(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []AzureZone{{SubscriptionID: "sub", ResourceGroup: "rg-legacy", Name: "corp.example", Private: true}}, zones)
+
+	names, err := client.ListRecordNames(context.Background(), zones[0])
+	assert.NoError(t, err, "legacy private zones are read through the dnszones API that listed them")
+	assert.Equal(t, []string{"intranet.corp.example"}, names)
+}
@@ -3,18 +3,24 @@ package discovery
 import (
 	"context"
 	"fmt"
+
+	"github.com/andre/ssl-cert-test/internal/config"
 	"github.com/cloudflare/cloudflare-go"
 )
 
 // Exported variable to allow overriding in tests
 var CloudflareBaseURL = "https://api.cloudflare.com/client/v4"
 
-// FetchDomainsFromCloudflare retrieves A and CNAME records from a Cloudflare Zone
-func FetchDomainsFromCloudflare(apiToken, zoneID string) ([]string, error) {
-	if apiToken == "" || zoneID == "" {
-		return nil, fmt.Errorf("cloudflare token and zone ID are required")
-	}
+// CloudflareOptions selects the zones to walk and how proxied records are handled
+type CloudflareOptions struct {
+	ZoneID     string   // Single zone to walk when no account is set
+	AccountID  string   // Walk every zone of the account instead of ZoneID
+	ZoneFilter []string // Zone name globs to keep when walking the account
+	ScanOrigin bool     // Also dial proxied records' content directly, using the name as SNI
+}
 
+// newCloudflareAPI creates a token-authenticated client honouring CloudflareBaseURL
+func newCloudflareAPI(apiToken string) (*cloudflare.API, error) {
 	// FIX: Use NewWithAPIToken instead of New
 	api, err := cloudflare.NewWithAPIToken(apiToken)
 	if err != nil {
@@ -25,15 +31,23 @@ func FetchDomainsFromCloudflare(apiToken, zoneID string) ([]string, error) {
 	if CloudflareBaseURL != "https://api.cloudflare.com/client/v4" {
 		api.BaseURL = CloudflareBaseURL
 	}
+	return api, nil
+}
 
-	ctx := context.Background()
+// FetchDomainsFromCloudflare retrieves A and CNAME records from a Cloudflare Zone
+func FetchDomainsFromCloudflare(apiToken, zoneID string) ([]string, error) {
+	if apiToken == "" || zoneID == "" {
+		return nil, fmt.Errorf("cloudflare token and zone ID are required")
+	}
 
-	// List DNS Records
-	records, _, err := api.ListDNSRecords(ctx, cloudflare.ZoneIdentifier(zoneID), cloudflare.ListDNSRecordsParams{
-		Type: "A,CNAME",
-	})
+	api, err := newCloudflareAPI(apiToken)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch DNS records: %w", err)
+		return nil, err
+	}
+
+	records, err := listCloudflareRecords(context.Background(), api, zoneID)
+	if err != nil {
+		return nil, err
 	}
 
 	var domains []string
@@ -45,3 +59,84 @@ func FetchDomainsFromCloudflare(apiToken, zoneID string) ([]string, error) {
 
 	return domains, nil
 }
+
+// FetchTargetsFromCloudflare walks one zone, or every zone of an account, and tags
+// each record as proxied or DNS-only. With ScanOrigin set, proxied records are also
+// scanned at their origin so certificates hidden behind the edge get checked too.
+func FetchTargetsFromCloudflare(apiToken string, opts CloudflareOptions) ([]config.Target, error) {
+	if apiToken == "" || (opts.ZoneID == "" && opts.AccountID == "") {
+		return nil, fmt.Errorf("cloudflare token and zone or account ID are required")
+	}
+
+	api, err := newCloudflareAPI(apiToken)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+
+	zones, err := listCloudflareZones(ctx, api, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []config.Target
+	for _, zone := range zones {
+		records, err := listCloudflareRecords(ctx, api, zone.ID)
+		if err != nil {
+			return nil, fmt.Errorf("zone %s: %w", zone.ID, err)
+		}
+
+		for _, r := range records {
+			if r.Name == "" {
+				continue
+			}
+			proxied := r.Proxied != nil && *r.Proxied
+			labels := func() map[string]string {
+				l := cloudflareZoneLabels(zone)
+				l["cloudflare_proxied"] = fmt.Sprint(proxied)
+				return l
+			}
+
+			targets = append(targets, config.Target{Host: r.Name, Labels: labels()})
+
+			if opts.ScanOrigin && proxied && r.Content != "" {
+				origin := labels()
+				origin["cloudflare_origin"] = "true"
+				targets = append(targets, config.Target{Host: r.Name, Address: r.Content, Labels: origin})
+			}
+		}
+	}
+
+	return targets, nil
+}
+
+// listCloudflareZones returns the single configured zone, or the account's zones passing the filter
+func listCloudflareZones(ctx context.Context, api *cloudflare.API, opts CloudflareOptions) ([]cloudflare.Zone, error) {
+	if opts.AccountID == "" {
+		return []cloudflare.Zone{{ID: opts.ZoneID}}, nil
+	}
+
+	resp, err := api.ListZonesContext(ctx, cloudflare.WithZoneFilters("", opts.AccountID, ""))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cloudflare zones: %w", err)
+	}
+
+	var zones []cloudflare.Zone
+	for _, z := range resp.Result {
+		if len(opts.ZoneFilter) == 0 || matchesAny(z.Name, opts.ZoneFilter) {
+			zones = append(zones, z)
+		}
+	}
+	return zones, nil
+}
+
+func listCloudflareRecords(ctx context.Context, api *cloudflare.API, zoneID string) ([]cloudflare.DNSRecord, error) {
+	// List DNS Records
+	records, _, err := api.ListDNSRecords(ctx, cloudflare.ZoneIdentifier(zoneID), cloudflare.ListDNSRecordsParams{
+		Type: "A,CNAME",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch DNS records: %w", err)
+	}
+	return records, nil
+}
@@ -0,0 +1,145 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/andre/ssl-cert-test/internal/config"
+	"github.com/andre/ssl-cert-test/internal/scan"
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// FetchCloudflareCertificates inventories the edge certificate packs and Origin CA
+// certificates of the selected zones as result rows, without a handshake
+func FetchCloudflareCertificates(apiToken string, opts CloudflareOptions, now time.Time) ([]config.DomainValidity, error) {
+	if apiToken == "" || (opts.ZoneID == "" && opts.AccountID == "") {
+		return nil, fmt.Errorf("cloudflare token and zone or account ID are required")
+	}
+
+	api, err := newCloudflareAPI(apiToken)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+
+	zones, err := listCloudflareZones(ctx, api, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []config.DomainValidity
+	for _, zone := range zones {
+		packs, err := api.ListCertificatePacks(ctx, zone.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list certificate packs for zone %s: %w", zone.ID, err)
+		}
+		for _, pack := range packs {
+			results = append(results, certificatePackResults(zone, pack, now)...)
+		}
+
+		origin, err := api.ListOriginCACertificates(ctx, cloudflare.ListOriginCertificatesParams{ZoneID: zone.ID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list origin ca certificates for zone %s: %w", zone.ID, err)
+		}
+		for _, cert := range origin {
+			if !cert.RevokedAt.IsZero() {
+				continue
+			}
+			results = append(results, originCAResult(zone, cert, now))
+		}
+	}
+
+	return results, nil
+}
+
+// certificatePackResults returns one row per certificate in the pack, or a single
+// error row when the pack has no issued certificate yet
+func certificatePackResults(zone cloudflare.Zone, pack cloudflare.CertificatePack, now time.Time) []config.DomainValidity {
+	labels := func() map[string]string {
+		l := cloudflareZoneLabels(zone)
+		l["cloudflare_pack_id"] = pack.ID
+		l["cloudflare_pack_type"] = pack.Type
+		return l
+	}
+
+	var statusErr string
+	if pack.Status != "active" {
+		statusErr = fmt.Sprintf("certificate pack status: %s", pack.Status)
+	}
+
+	if len(pack.Certificates) == 0 {
+		return []config.DomainValidity{{
+			Domain:          firstHost(pack.Hosts),
+			SANs:            pack.Hosts,
+			Issuer:          pack.CertificateAuthority,
+			ChainStatus:     pack.Status,
+			Error:           statusErr,
+			DaysUntilExpiry: 999999,
+			Labels:          labels(),
+			Source:          "cloudflare-certpack",
+		}}
+	}
+
+	var results []config.DomainValidity
+	for _, cert := range pack.Certificates {
+		l := labels()
+		l["cloudflare_cert_id"] = cert.ID
+
+		issuer := cert.Issuer
+		if issuer == "" {
+			issuer = pack.CertificateAuthority
+		}
+
+		results = append(results, config.DomainValidity{
+			Domain:          firstHost(cert.Hosts),
+			SANs:            cert.Hosts,
+			Issuer:          issuer,
+			SignatureAlgo:   cert.Signature,
+			ChainStatus:     cert.Status,
+			NotAfter:        cert.ExpiresOn,
+			DaysUntilExpiry: scan.DaysUntil(cert.ExpiresOn, now),
+			Error:           statusErr,
+			Labels:          l,
+			Source:          "cloudflare-certpack",
+		})
+	}
+	return results
+}
+
+// originCAResult parses the Origin CA certificate when the API returns it, falling
+// back to the listed hostnames and expiry otherwise
+func originCAResult(zone cloudflare.Zone, cert cloudflare.OriginCACertificate, now time.Time) config.DomainValidity {
+	var result config.DomainValidity
+	if certs, err := scan.ParsePEMCertificates([]byte(cert.Certificate)); err == nil {
+		result = scan.CertificateResult("cloudflare-origin-ca", firstHost(cert.Hostnames), certs[0], now)
+	} else {
+		result = config.DomainValidity{
+			Domain:          firstHost(cert.Hostnames),
+			SANs:            cert.Hostnames,
+			NotAfter:        cert.ExpiresOn,
+			DaysUntilExpiry: scan.DaysUntil(cert.ExpiresOn, now),
+			Source:          "cloudflare-origin-ca",
+		}
+	}
+
+	result.ChainStatus = "Cloudflare Origin CA"
+	result.Labels = cloudflareZoneLabels(zone)
+	result.Labels["cloudflare_cert_id"] = cert.ID
+	return result
+}
+
+func cloudflareZoneLabels(zone cloudflare.Zone) map[string]string {
+	l := map[string]string{"cloudflare_zone_id": zone.ID}
+	if zone.Name != "" {
+		l["cloudflare_zone"] = zone.Name
+	}
+	return l
+}
+
+func firstHost(hosts []string) string {
+	if len(hosts) == 0 {
+		return ""
+	}
+	return hosts[0]
+}
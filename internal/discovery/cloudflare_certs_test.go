@@ -0,0 +1,130 @@
+package discovery
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testCertPEM returns a self-signed PEM certificate for the given names
+func testCertPEM(t *testing.T, cn string, hosts []string, notAfter time.Time) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		Issuer:       pkix.Name{CommonName: cn},
+		DNSNames:     hosts,
+		NotBefore:    notAfter.Add(-90 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestFetchCloudflareCertificates(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	originPEM := testCertPEM(t, "origin.example.com", []string{"origin.example.com", "*.example.com"}, now.Add(10*24*time.Hour))
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/zones/zone-a/ssl/certificate_packs":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": true,
+				"result": []map[string]interface{}{
+					{
+						"id": "pack-1", "type": "universal", "status": "active",
+						"hosts":                 []string{"example.com", "*.example.com"},
+						"certificate_authority": "lets_encrypt",
+						"certificates": []map[string]interface{}{
+							{
+								"id": "cert-1", "hosts": []string{"example.com", "*.example.com"},
+								"issuer": "LetsEncrypt", "signature": "ECDSAWithSHA256", "status": "active",
+								"expires_on": now.Add(30 * 24 * time.Hour).Format(time.RFC3339),
+							},
+						},
+					},
+					{
+						"id": "pack-2", "type": "advanced", "status": "pending_validation",
+						"hosts": []string{"shop.example.com"},
+					},
+				},
+			})
+		case "/certificates":
+			if r.URL.Query().Get("zone_id") != "zone-a" {
+				t.Errorf("Expected zone_id=zone-a, got %s", r.URL.Query().Get("zone_id"))
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": true,
+				"result": []map[string]interface{}{
+					{
+						"id": "origin-1", "certificate": string(originPEM), "hostnames": []string{"origin.example.com"},
+						"expires_on": now.Add(10 * 24 * time.Hour).Format(time.RFC3339),
+					},
+					{
+						"id": "origin-2", "hostnames": []string{"old.example.com"},
+						"expires_on": now.Add(24 * time.Hour).Format(time.RFC3339),
+						"revoked_at": now.Add(-time.Hour).Format(time.RFC3339),
+					},
+				},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	originalURL := CloudflareBaseURL
+	CloudflareBaseURL = ts.URL
+	defer func() { CloudflareBaseURL = originalURL }()
+
+	results, err := FetchCloudflareCertificates("test-token", CloudflareOptions{ZoneID: "zone-a"}, now)
+
+	assert.NoError(t, err)
+	assert.Len(t, results, 3, "revoked origin certificates are skipped")
+
+	edge := results[0]
+	assert.Equal(t, "cloudflare-certpack", edge.Source)
+	assert.Equal(t, "example.com", edge.Domain)
+	assert.Equal(t, "LetsEncrypt", edge.Issuer)
+	assert.Equal(t, 30, edge.DaysUntilExpiry)
+	assert.Empty(t, edge.Error)
+	assert.Equal(t, "pack-1", edge.Labels["cloudflare_pack_id"])
+
+	pending := results[1]
+	assert.Equal(t, "shop.example.com", pending.Domain)
+	assert.Equal(t, 999999, pending.DaysUntilExpiry)
+	assert.Contains(t, pending.Error, "pending_validation")
+
+	origin := results[2]
+	assert.Equal(t, "cloudflare-origin-ca", origin.Source)
+	assert.Equal(t, "origin.example.com", origin.CommonName)
+	assert.Equal(t, []string{"origin.example.com", "*.example.com"}, origin.SANs)
+	assert.Equal(t, 10, origin.DaysUntilExpiry)
+	assert.Equal(t, "origin-1", origin.Labels["cloudflare_cert_id"])
+}
+
+func TestFetchCloudflareCertificates_MissingArgs(t *testing.T) {
+	_, err := FetchCloudflareCertificates("", CloudflareOptions{}, time.Now())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "required")
+}
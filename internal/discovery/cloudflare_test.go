@@ -89,3 +89,78 @@ func TestFetchDomainsFromCloudflare_MissingArgs(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "required")
 }
+
+func TestFetchTargetsFromCloudflare_Account(t *testing.T) {
+	records := map[string][]map[string]interface{}{
+		"zone-a": {
+			{"name": "www.example.com", "type": "A", "content": "203.0.113.10", "proxied": true},
+			{"name": "mail.example.com", "type": "A", "content": "203.0.113.20", "proxied": false},
+		},
+		"zone-b": {
+			{"name": "shop.example.net", "type": "CNAME", "content": "origin.example.net", "proxied": true},
+		},
+		"zone-c": {
+			{"name": "dev.test.org", "type": "A", "content": "198.51.100.1", "proxied": true},
+		},
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.URL.Path == "/zones" {
+			if r.URL.Query().Get("account.id") != "acct-1" {
+				t.Errorf("Expected account.id=acct-1, got %s", r.URL.Query().Get("account.id"))
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": true,
+				"result": []map[string]interface{}{
+					{"id": "zone-a", "name": "example.com"},
+					{"id": "zone-b", "name": "example.net"},
+					{"id": "zone-c", "name": "test.org"},
+				},
+				"result_info": map[string]int{"page": 1, "per_page": 50, "total_pages": 1, "count": 3, "total_count": 3},
+			})
+			return
+		}
+
+		for id, recs := range records {
+			if r.URL.Path == "/zones/"+id+"/dns_records" {
+				json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "result": recs})
+				return
+			}
+		}
+		http.NotFound(w, r)
+	}))
+	defer ts.Close()
+
+	originalURL := CloudflareBaseURL
+	CloudflareBaseURL = ts.URL
+	defer func() { CloudflareBaseURL = originalURL }()
+
+	targets, err := FetchTargetsFromCloudflare("test-token", CloudflareOptions{
+		AccountID:  "acct-1",
+		ZoneFilter: []string{"example.*"},
+		ScanOrigin: true,
+	})
+
+	assert.NoError(t, err)
+
+	got := make(map[string]map[string]string)
+	for _, target := range targets {
+		got[target.Host+"@"+target.Address] = target.Labels
+	}
+	assert.Len(t, got, 5)
+	assert.Equal(t, "true", got["www.example.com@"]["cloudflare_proxied"])
+	assert.Equal(t, "example.com", got["www.example.com@"]["cloudflare_zone"])
+	assert.Equal(t, "true", got["www.example.com@203.0.113.10"]["cloudflare_origin"])
+	assert.Equal(t, "false", got["mail.example.com@"]["cloudflare_proxied"])
+	assert.NotContains(t, got, "mail.example.com@203.0.113.20", "DNS-only records are already scanned at the origin")
+	assert.Equal(t, "true", got["shop.example.net@origin.example.net"]["cloudflare_origin"])
+	assert.NotContains(t, got, "dev.test.org@", "Zone filter should drop test.org")
+}
+
+func TestFetchTargetsFromCloudflare_MissingArgs(t *testing.T) {
+	_, err := FetchTargetsFromCloudflare("token", CloudflareOptions{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "required")
+}
@@ -0,0 +1,138 @@
+package discovery
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/andre/ssl-cert-test/internal/config"
+	"github.com/miekg/dns"
+)
+
+// TSIGKey signs zone transfers; an empty Name means an unsigned transfer
+type TSIGKey struct {
+	Name      string
+	Secret    string // Base64, as in BIND's key statement
+	Algorithm string // e.g. hmac-sha256; defaults to hmac-sha256
+}
+
+// FetchTargetsFromZoneFile parses an RFC 1035 zone file, following $ORIGIN and
+// $INCLUDE, and returns its A/AAAA/CNAME names and SRV endpoints. origin is used
+// for relative names until the file sets its own $ORIGIN.
+func FetchTargetsFromZoneFile(path, origin string) ([]config.Target, error) {
+	if path == "" {
+		return nil, fmt.Errorf("zone file path is required")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zone file: %w", err)
+	}
+	defer f.Close()
+
+	if origin != "" {
+		origin = dns.Fqdn(origin)
+	}
+	zp := dns.NewZoneParser(f, origin, path)
+	zp.SetIncludeAllowed(true)
+
+	var records []dns.RR
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		records = append(records, rr)
+	}
+	if err := zp.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse zone file: %w", err)
+	}
+
+	return dnsRecordTargets(records, originOf(records, origin)), nil
+}
+
+// FetchTargetsFromAXFR transfers the zone from the nameserver (host or host:port)
+// and returns its A/AAAA/CNAME names and SRV endpoints
+func FetchTargetsFromAXFR(server, zone string, key TSIGKey) ([]config.Target, error) {
+	if server == "" || zone == "" {
+		return nil, fmt.Errorf("axfr server and zone are required")
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	zone = dns.Fqdn(zone)
+
+	msg := new(dns.Msg)
+	msg.SetAxfr(zone)
+
+	transfer := &dns.Transfer{}
+	if key.Name != "" {
+		name := dns.Fqdn(key.Name)
+		algorithm := key.Algorithm
+		if algorithm == "" {
+			algorithm = dns.HmacSHA256
+		}
+		msg.SetTsig(name, dns.Fqdn(algorithm), 300, time.Now().Unix())
+		transfer.TsigSecret = map[string]string{name: key.Secret}
+	}
+
+	envelopes, err := transfer.In(msg, server)
+	if err != nil {
+		return nil, fmt.Errorf("axfr of %s from %s failed: %w", zone, server, err)
+	}
+
+	var records []dns.RR
+	for env := range envelopes {
+		if env.Error != nil {
+			return nil, fmt.Errorf("axfr of %s from %s failed: %w", zone, server, env.Error)
+		}
+		records = append(records, env.RR...)
+	}
+
+	return dnsRecordTargets(records, zone), nil
+}
+
+// originOf returns the zone apex from the SOA record, falling back to origin
+func originOf(records []dns.RR, origin string) string {
+	for _, rr := range records {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa.Hdr.Name
+		}
+	}
+	return origin
+}
+
+// dnsRecordTargets keeps A/AAAA/CNAME owner names and SRV targets with their port,
+// skipping wildcards and duplicates
+func dnsRecordTargets(records []dns.RR, zone string) []config.Target {
+	zoneName := config.NormalizeHost(zone)
+	seen := make(map[string]bool)
+	var targets []config.Target
+
+	add := func(host string, port uint16, srv string) {
+		host = config.NormalizeHost(host)
+		key := fmt.Sprintf("%s|%d", host, port)
+		if host == "" || strings.HasPrefix(host, "*.") || seen[key] {
+			return
+		}
+		seen[key] = true
+
+		target := config.Target{Host: host, Labels: map[string]string{"dns_zone": zoneName}}
+		if port != 0 {
+			target.Ports = []int{int(port)}
+			target.Labels["dns_srv"] = config.NormalizeHost(srv)
+		}
+		targets = append(targets, target)
+	}
+
+	for _, rr := range records {
+		switch r := rr.(type) {
+		case *dns.A, *dns.AAAA, *dns.CNAME:
+			add(rr.Header().Name, 0, "")
+		case *dns.SRV:
+			// "." means the service is explicitly not available
+			if r.Target != "." && r.Port != 0 {
+				add(r.Target, r.Port, r.Hdr.Name)
+			}
+		}
+	}
+	return targets
+}
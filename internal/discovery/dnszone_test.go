@@ -0,0 +1,128 @@
+package discovery
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchTargetsFromZoneFile(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "internal.inc"), []byte(`
+$ORIGIN internal.example.com.
+db      IN A     10.0.0.5
+`), 0o644)
+	os.WriteFile(filepath.Join(dir, "example.com.zone"), []byte(`
+$TTL 300
+@       IN SOA   ns1 hostmaster 1 7200 3600 1209600 300
+        IN NS    ns1
+        IN A     192.0.2.1
+        IN AAAA  2001:db8::1
+www     IN CNAME @
+*       IN A     192.0.2.1
+mail    IN MX    10 mx.example.net.
+_ldaps._tcp IN SRV 0 0 636 ldap
+_none._tcp  IN SRV 0 0 0 .
+ldap    IN A     192.0.2.10
+$INCLUDE `+filepath.Join(dir, "internal.inc")+`
+`), 0o644)
+
+	targets, err := FetchTargetsFromZoneFile(filepath.Join(dir, "example.com.zone"), "example.com")
+
+	assert.NoError(t, err)
+	var hosts []string
+	for _, tgt := range targets {
+		hosts = append(hosts, tgt.Host)
+	}
+	// A and AAAA at the apex collapse to one target; wildcards, MX and "." SRV targets are skipped
+	assert.Equal(t, []string{"example.com", "www.example.com", "ldap.example.com", "ldap.example.com", "db.internal.example.com"}, hosts)
+
+	srv := targets[2]
+	assert.Equal(t, []int{636}, srv.Ports)
+	assert.Equal(t, "_ldaps._tcp.example.com", srv.Labels["dns_srv"])
+	assert.Equal(t, "example.com", srv.Labels["dns_zone"])
+	assert.Empty(t, targets[3].Ports)
+}
+
+func TestFetchTargetsFromZoneFile_Missing(t *testing.T) {
+	_, err := FetchTargetsFromZoneFile(filepath.Join(t.TempDir(), "nope.zone"), "example.com")
+	assert.Error(t, err)
+}
+
+// serveAXFR starts an in-process TCP nameserver answering AXFR for example.com,
+// requiring a valid TSIG signature when secrets are set
+func serveAXFR(t *testing.T, secrets map[string]string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := func(s string) dns.RR {
+		r, err := dns.NewRR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	soa := rr("example.com. 300 IN SOA ns1.example.com. hostmaster.example.com. 1 7200 3600 1209600 300")
+
+	mux := dns.NewServeMux()
+	mux.HandleFunc("example.com.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		if secrets != nil && (req.IsTsig() == nil || w.TsigStatus() != nil) {
+			m.Rcode = dns.RcodeNotAuth
+			w.WriteMsg(m)
+			return
+		}
+		m.Answer = []dns.RR{
+			soa,
+			rr("api.example.com. 300 IN A 192.0.2.20"),
+			rr("_https._tcp.example.com. 300 IN SRV 0 0 8443 api.example.com."),
+			soa,
+		}
+		if tsig := req.IsTsig(); tsig != nil {
+			m.SetTsig(tsig.Hdr.Name, tsig.Algorithm, 300, time.Now().Unix())
+		}
+		w.WriteMsg(m)
+	})
+
+	srv := &dns.Server{Listener: ln, Handler: mux, TsigSecret: secrets}
+	started := make(chan struct{})
+	srv.NotifyStartedFunc = func() { close(started) }
+	go srv.ActivateAndServe()
+	<-started
+	t.Cleanup(func() { srv.Shutdown() })
+
+	return ln.Addr().String()
+}
+
+func TestFetchTargetsFromAXFR(t *testing.T) {
+	secret := "c2VjcmV0LWtleS1mb3ItdGVzdHM="
+	addr := serveAXFR(t, map[string]string{"xfer.": secret})
+
+	targets, err := FetchTargetsFromAXFR(addr, "example.com", TSIGKey{Name: "xfer", Secret: secret})
+
+	assert.NoError(t, err)
+	assert.Len(t, targets, 2)
+	assert.Equal(t, "api.example.com", targets[0].Host)
+	assert.Empty(t, targets[0].Ports)
+	assert.Equal(t, "api.example.com", targets[1].Host)
+	assert.Equal(t, []int{8443}, targets[1].Ports)
+
+	// Unsigned transfers are refused by this server
+	_, err = FetchTargetsFromAXFR(addr, "example.com", TSIGKey{})
+	assert.Error(t, err)
+}
+
+func TestFetchTargetsFromAXFR_MissingArgs(t *testing.T) {
+	_, err := FetchTargetsFromAXFR("", "", TSIGKey{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "required")
+}
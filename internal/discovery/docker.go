@@ -0,0 +1,218 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andre/ssl-cert-test/internal/config"
+)
+
+// DockerOptions selects the Docker Engine to query. Host is a unix:// or
+// tcp:// address and defaults to $DOCKER_HOST, then the local socket.
+// Published ports bound to every interface are dialled at Address.
+type DockerOptions struct {
+	Host    string
+	Address string
+}
+
+// Container labels that opt a container in and tune its scan
+const (
+	dockerLabelEnable   = "sslcheck.enable"   // "true" to scan the container
+	dockerLabelSNI      = "sslcheck.sni"      // Comma-separated server names to send
+	dockerLabelPorts    = "sslcheck.ports"    // Comma-separated container ports; default every published TCP port
+	dockerLabelProtocol = "sslcheck.protocol" // STARTTLS protocol, e.g. smtp
+)
+
+const defaultDockerHost = "unix:///var/run/docker.sock"
+
+type dockerContainer struct {
+	ID     string            `json:"Id"`
+	Names  []string          `json:"Names"`
+	Image  string            `json:"Image"`
+	Labels map[string]string `json:"Labels"`
+	Ports  []struct {
+		IP          string `json:"IP"`
+		PrivatePort int    `json:"PrivatePort"`
+		PublicPort  int    `json:"PublicPort"`
+		Type        string `json:"Type"`
+	} `json:"Ports"`
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress string `json:"IPAddress"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+// dockerEndpoint is where one container port can be reached
+type dockerEndpoint struct {
+	Address string
+	Port    int
+}
+
+// FetchTargetsFromDocker lists the running containers labelled
+// sslcheck.enable=true and returns a target per endpoint they expose: the
+// published host port when there is one, the container IP otherwise.
+func FetchTargetsFromDocker(opts DockerOptions) ([]config.Target, error) {
+	client, baseURL, err := newDockerClient(opts.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	filters, _ := json.Marshal(map[string][]string{"label": {dockerLabelEnable + "=true"}})
+	resp, err := client.Get(baseURL + "/containers/json?filters=" + url.QueryEscape(string(filters)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query docker engine: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("docker engine returned status %d", resp.StatusCode)
+	}
+
+	var containers []dockerContainer
+	if err := json.NewDecoder(resp.Body).Decode(&containers); err != nil {
+		return nil, fmt.Errorf("failed to parse docker engine response: %w", err)
+	}
+
+	var targets []config.Target
+	for _, c := range containers {
+		// The filter is repeated here for engines that ignore it
+		if !strings.EqualFold(c.Labels[dockerLabelEnable], "true") {
+			continue
+		}
+		targets = append(targets, dockerTargets(c, opts.Address)...)
+	}
+	return targets, nil
+}
+
+// dockerTargets builds one target per SNI name and address, carrying every
+// port reachable at that address
+func dockerTargets(c dockerContainer, defaultAddress string) []config.Target {
+	name := c.ID
+	if len(c.Names) > 0 {
+		name = strings.TrimPrefix(c.Names[0], "/")
+	}
+
+	var wanted []int
+	for _, p := range config.SplitList(c.Labels[dockerLabelPorts]) {
+		if port, err := strconv.Atoi(p); err == nil {
+			wanted = append(wanted, port)
+		}
+	}
+
+	var endpoints []dockerEndpoint
+	published := make(map[int]bool)
+	for _, p := range c.Ports {
+		if p.Type != "tcp" || p.PublicPort == 0 || (len(wanted) > 0 && !slices.Contains(wanted, p.PrivatePort)) {
+			continue
+		}
+		address := p.IP
+		if address == "" || address == "0.0.0.0" || address == "::" {
+			address = defaultAddress
+		}
+		ep := dockerEndpoint{Address: address, Port: p.PublicPort}
+		// Docker lists a port once per address family
+		if !slices.Contains(endpoints, ep) {
+			endpoints = append(endpoints, ep)
+		}
+		published[p.PrivatePort] = true
+	}
+
+	// Requested ports that are not published are reached on the container network
+	if containerIP := dockerContainerIP(c); containerIP != "" {
+		for _, port := range wanted {
+			if !published[port] {
+				endpoints = append(endpoints, dockerEndpoint{Address: containerIP, Port: port})
+			}
+		}
+	}
+
+	// Group ports by address so each address is one target
+	var addresses []string
+	ports := make(map[string][]int)
+	for _, ep := range endpoints {
+		if _, ok := ports[ep.Address]; !ok {
+			addresses = append(addresses, ep.Address)
+		}
+		ports[ep.Address] = append(ports[ep.Address], ep.Port)
+	}
+
+	labels := map[string]string{"docker_container": name, "docker_image": c.Image}
+	names := config.SplitList(c.Labels[dockerLabelSNI])
+
+	var targets []config.Target
+	for _, address := range addresses {
+		base := config.Target{
+			Host:     address,
+			Ports:    ports[address],
+			Protocol: c.Labels[dockerLabelProtocol],
+			Labels:   labels,
+		}
+		if len(names) == 0 {
+			targets = append(targets, base)
+			continue
+		}
+		for _, sni := range names {
+			t := base
+			t.Host = config.NormalizeHost(sni)
+			t.Address = address
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
+// dockerContainerIP returns the container's address on its first network, by name
+func dockerContainerIP(c dockerContainer) string {
+	var networks []string
+	for name, n := range c.NetworkSettings.Networks {
+		if n.IPAddress != "" {
+			networks = append(networks, name)
+		}
+	}
+	if len(networks) == 0 {
+		return ""
+	}
+	slices.Sort(networks)
+	return c.NetworkSettings.Networks[networks[0]].IPAddress
+}
+
+// newDockerClient returns an HTTP client for the engine and the base URL to
+// use with it; unix sockets are dialled whatever the URL host says
+func newDockerClient(host string) (*http.Client, string, error) {
+	if host == "" {
+		host = os.Getenv("DOCKER_HOST")
+	}
+	if host == "" {
+		host = defaultDockerHost
+	}
+
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid docker host %q: %w", host, err)
+	}
+
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		}
+		return &http.Client{Transport: transport, Timeout: 30 * time.Second}, "http://docker", nil
+	case "tcp", "http":
+		return &http.Client{Timeout: 30 * time.Second}, "http://" + u.Host, nil
+	default:
+		return nil, "", fmt.Errorf("unsupported docker host %q: use unix:// or tcp://", host)
+	}
+}
@@ -0,0 +1,84 @@
+package discovery
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/andre/ssl-cert-test/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+const dockerContainersJSON = `[
+  {
+    "Id": "a1", "Names": ["/shop"], "Image": "nginx:1.27",
+    "Labels": {"sslcheck.enable": "true", "sslcheck.sni": "shop.example.com,www.shop.example.com"},
+    "Ports": [
+      {"IP": "0.0.0.0", "PrivatePort": 443, "PublicPort": 8443, "Type": "tcp"},
+      {"IP": "::", "PrivatePort": 443, "PublicPort": 8443, "Type": "tcp"},
+      {"IP": "0.0.0.0", "PrivatePort": 53, "PublicPort": 53, "Type": "udp"}
+    ]
+  },
+  {
+    "Id": "b2", "Names": ["/mail"], "Image": "postfix:latest",
+    "Labels": {"sslcheck.enable": "true", "sslcheck.ports": "587,9443", "sslcheck.protocol": "smtp"},
+    "Ports": [
+      {"IP": "192.0.2.5", "PrivatePort": 587, "PublicPort": 587, "Type": "tcp"},
+      {"IP": "0.0.0.0", "PrivatePort": 25, "PublicPort": 25, "Type": "tcp"}
+    ],
+    "NetworkSettings": {"Networks": {"bridge": {"IPAddress": "172.17.0.3"}}}
+  },
+  {
+    "Id": "c3", "Names": ["/worker"], "Image": "worker:1",
+    "Labels": {"sslcheck.enable": "false"},
+    "Ports": [{"IP": "0.0.0.0", "PrivatePort": 443, "PublicPort": 443, "Type": "tcp"}]
+  }
+]`
+
+func TestFetchTargetsFromDocker(t *testing.T) {
+	var gotFilters map[string][]string
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/containers/json", r.URL.Path)
+		json.Unmarshal([]byte(r.URL.Query().Get("filters")), &gotFilters)
+		w.Write([]byte(dockerContainersJSON))
+	}))
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Listener = listener
+	ts.Start()
+	defer ts.Close()
+
+	targets, err := FetchTargetsFromDocker(DockerOptions{Host: "unix://" + socket, Address: "127.0.0.1"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{"label": {"sslcheck.enable=true"}}, gotFilters)
+
+	shop := map[string]string{"docker_container": "shop", "docker_image": "nginx:1.27"}
+	mail := map[string]string{"docker_container": "mail", "docker_image": "postfix:latest"}
+	assert.Equal(t, []config.Target{
+		{Host: "shop.example.com", Address: "127.0.0.1", Ports: []int{8443}, Labels: shop},
+		{Host: "www.shop.example.com", Address: "127.0.0.1", Ports: []int{8443}, Labels: shop},
+		{Host: "192.0.2.5", Ports: []int{587}, Protocol: "smtp", Labels: mail},
+		{Host: "172.17.0.3", Ports: []int{9443}, Protocol: "smtp", Labels: mail},
+	}, targets, "udp, unlisted and disabled ports are skipped; unpublished ports use the container IP")
+}
+
+func TestFetchTargetsFromDocker_Errors(t *testing.T) {
+	_, err := FetchTargetsFromDocker(DockerOptions{Host: "ssh://docker.example.com"})
+	assert.ErrorContains(t, err, "unsupported docker host")
+
+	_, err = FetchTargetsFromDocker(DockerOptions{Host: "unix://" + filepath.Join(t.TempDir(), "missing.sock")})
+	assert.ErrorContains(t, err, "failed to query docker engine")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer ts.Close()
+	_, err = FetchTargetsFromDocker(DockerOptions{Host: "tcp://" + ts.Listener.Addr().String()})
+	assert.ErrorContains(t, err, "status 403")
+}
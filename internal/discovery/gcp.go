@@ -0,0 +1,155 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/andre/ssl-cert-test/internal/config"
+	dns "google.golang.org/api/dns/v1"
+	"google.golang.org/api/option"
+)
+
+// Exported variable to allow pointing the Cloud DNS client at a local fake API in tests
+var GCPEndpointURL = ""
+
+// GCPZone is a public or private Cloud DNS managed zone found in a project
+type GCPZone struct {
+	Project string
+	Name    string // Managed zone resource name, e.g. "example-com"
+	DNSName string // Domain served by the zone, e.g. "example.com"
+	Private bool
+}
+
+// GCPDNSClient enumerates the managed zones of one project and the A/AAAA/CNAME
+// names inside them
+type GCPDNSClient interface {
+	ListZones(ctx context.Context) ([]GCPZone, error)
+	ListRecordNames(ctx context.Context, zone GCPZone) ([]string, error)
+}
+
+// GCPDNSClientCreatorFunc is a variable we can swap in tests to return a mock client.
+// Without a credentials file it uses Application Default Credentials, which covers
+// workload identity on GKE and attached service accounts on GCE.
+var GCPDNSClientCreatorFunc = func(project, credentialsFile string) (GCPDNSClient, error) {
+	var opts []option.ClientOption
+	if credentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(credentialsFile))
+	}
+	if GCPEndpointURL != "" {
+		opts = append(opts, option.WithEndpoint(GCPEndpointURL), option.WithoutAuthentication())
+	}
+
+	svc, err := dns.NewService(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gcp dns client: %w", err)
+	}
+	return &realGCPDNSClient{project: project, svc: svc}, nil
+}
+
+// FetchTargetsFromGCP walks every managed zone of the given projects and tags each
+// name with its project and zone. Projects that fail are returned as error rows
+// instead of aborting.
+func FetchTargetsFromGCP(projects []string, credentialsFile string) ([]config.Target, []config.DomainValidity, error) {
+	if len(projects) == 0 {
+		return nil, nil, fmt.Errorf("at least one gcp project is required")
+	}
+
+	var targets []config.Target
+	var failures []config.DomainValidity
+	ctx := context.Background()
+
+	for _, project := range projects {
+		found, err := gcpProjectTargets(ctx, project, credentialsFile)
+		if err != nil {
+			slog.Warn("gcp project discovery failed", "project", project, "error", err)
+			failures = append(failures, discoveryError("gcp", "gcp:"+project, map[string]string{"gcp_project": project}, err))
+			continue
+		}
+		targets = append(targets, found...)
+	}
+
+	return targets, failures, nil
+}
+
+func gcpProjectTargets(ctx context.Context, project, credentialsFile string) ([]config.Target, error) {
+	client, err := GCPDNSClientCreatorFunc(project, credentialsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	zones, err := client.ListZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []config.Target
+	for _, zone := range zones {
+		names, err := client.ListRecordNames(ctx, zone)
+		if err != nil {
+			return nil, fmt.Errorf("zone %s: %w", zone.Name, err)
+		}
+		for _, name := range names {
+			targets = append(targets, config.Target{
+				Host: name,
+				Labels: map[string]string{
+					"gcp_project": zone.Project,
+					"gcp_zone":    zone.Name,
+					"gcp_private": fmt.Sprint(zone.Private),
+				},
+			})
+		}
+	}
+	return targets, nil
+}
+
+// realGCPDNSClient talks to the Cloud DNS v1 API
+type realGCPDNSClient struct {
+	project string
+	svc     *dns.Service
+}
+
+func (c *realGCPDNSClient) ListZones(ctx context.Context) ([]GCPZone, error) {
+	var zones []GCPZone
+	err := c.svc.ManagedZones.List(c.project).Pages(ctx, func(page *dns.ManagedZonesListResponse) error {
+		for _, z := range page.ManagedZones {
+			zones = append(zones, GCPZone{
+				Project: c.project,
+				Name:    z.Name,
+				DNSName: config.NormalizeHost(z.DnsName),
+				Private: z.Visibility == "private",
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list managed zones: %w", err)
+	}
+	return zones, nil
+}
+
+func (c *realGCPDNSClient) ListRecordNames(ctx context.Context, zone GCPZone) ([]string, error) {
+	var names []string
+	seen := map[string]bool{}
+	err := c.svc.ResourceRecordSets.List(c.project, zone.Name).Pages(ctx, func(page *dns.ResourceRecordSetsListResponse) error {
+		for _, rr := range page.Rrsets {
+			switch rr.Type {
+			case "A", "AAAA", "CNAME":
+			default:
+				continue
+			}
+			name := config.NormalizeHost(rr.Name)
+			if name == "" || strings.HasPrefix(name, "*.") || seen[name] {
+				continue
+			}
+			seen[name] = true
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list record sets: %w", err)
+	}
+	return names, nil
+}
@@ -0,0 +1,92 @@
+package discovery
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeCloudDNS serves the managed zone and record set list endpoints of the Cloud DNS v1 API
+func fakeCloudDNS(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		path := strings.TrimPrefix(r.URL.Path, "/dns/v1")
+
+		switch path {
+		case "/projects/prod/managedZones":
+			// Page through the zones to exercise the pager
+			if r.URL.Query().Get("pageToken") == "" {
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"managedZones":  []map[string]string{{"name": "example-com", "dnsName": "example.com.", "visibility": "public"}},
+					"nextPageToken": "next",
+				})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"managedZones": []map[string]string{{"name": "corp-internal", "dnsName": "corp.internal.", "visibility": "private"}},
+			})
+		case "/projects/prod/managedZones/example-com/rrsets":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"rrsets": []map[string]interface{}{
+					{"name": "example.com.", "type": "A", "rrdatas": []string{"1.2.3.4"}},
+					{"name": "example.com.", "type": "AAAA", "rrdatas": []string{"2001:db8::1"}},
+					{"name": "WWW.example.com.", "type": "CNAME", "rrdatas": []string{"example.com."}},
+					{"name": "example.com.", "type": "MX", "rrdatas": []string{"10 mail.example.com."}},
+					{"name": "*.example.com.", "type": "A", "rrdatas": []string{"1.2.3.4"}},
+				},
+			})
+		case "/projects/prod/managedZones/corp-internal/rrsets":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"rrsets": []map[string]interface{}{
+					{"name": "db.corp.internal.", "type": "A", "rrdatas": []string{"10.0.0.5"}},
+				},
+			})
+		case "/projects/denied/managedZones":
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": map[string]interface{}{"code": 403, "message": "permission denied on project"},
+			})
+		default:
+			t.Errorf("unexpected request: %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestFetchTargetsFromGCP(t *testing.T) {
+	ts := fakeCloudDNS(t)
+	defer ts.Close()
+
+	originalURL := GCPEndpointURL
+	GCPEndpointURL = ts.URL + "/"
+	defer func() { GCPEndpointURL = originalURL }()
+
+	targets, failures, err := FetchTargetsFromGCP([]string{"prod", "denied"}, "")
+
+	assert.NoError(t, err)
+
+	var hosts []string
+	for _, tgt := range targets {
+		hosts = append(hosts, tgt.Host)
+	}
+	assert.Equal(t, []string{"example.com", "www.example.com", "db.corp.internal"}, hosts, "MX and wildcard records are skipped, A/AAAA deduped")
+	assert.Len(t, targets, 3)
+
+	assert.Equal(t, map[string]string{"gcp_project": "prod", "gcp_zone": "example-com", "gcp_private": "false"}, targets[0].Labels)
+	assert.Equal(t, "true", targets[2].Labels["gcp_private"])
+
+	assert.Len(t, failures, 1)
+	assert.Equal(t, "gcp:denied", failures[0].Domain)
+	assert.Equal(t, "gcp", failures[0].Source)
+	assert.Contains(t, failures[0].Error, "permission denied")
+}
+
+func TestFetchTargetsFromGCP_MissingArgs(t *testing.T) {
+	_, _, err := FetchTargetsFromGCP(nil, "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "required")
+}
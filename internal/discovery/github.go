@@ -0,0 +1,82 @@
+package discovery
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/andre/ssl-cert-test/internal/config"
+)
+
+// GitHubBaseURL is the default REST API root, exported so tests can point it at a fake
+var GitHubBaseURL = "https://api.github.com"
+
+// GitHubOptions points at one target file in a GitHub repository
+type GitHubOptions struct {
+	Token string
+	Repo  string // owner/name
+	Path  string
+	Ref   string // Branch, tag or commit; empty uses the default branch
+
+	BaseURL string // GitHub Enterprise API root, e.g. https://ghe.example.com/api/v3
+}
+
+// FetchGitHubConfig downloads a JSON or YAML target file through the contents
+// API and parses it like a local config file
+func FetchGitHubConfig(opts GitHubOptions) (config.Config, error) {
+	owner, repo, ok := strings.Cut(opts.Repo, "/")
+	if !ok || owner == "" || repo == "" || opts.Path == "" {
+		return config.Config{}, fmt.Errorf("github repository (owner/name) and file path are required")
+	}
+
+	baseURL := opts.BaseURL
+	if baseURL == "" {
+		baseURL = GitHubBaseURL
+	}
+
+	u := fmt.Sprintf("%s/repos/%s/%s/contents/%s", strings.TrimSuffix(baseURL, "/"),
+		url.PathEscape(owner), url.PathEscape(repo), escapePath(strings.TrimPrefix(opts.Path, "/")))
+	if opts.Ref != "" {
+		u += "?ref=" + url.QueryEscape(opts.Ref)
+	}
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return config.Config{}, fmt.Errorf("invalid github url: %w", err)
+	}
+	// The raw media type returns the file itself instead of base64 JSON
+	req.Header.Set("Accept", "application/vnd.github.raw+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.Token)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return config.Config{}, fmt.Errorf("failed to fetch file from github: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return config.Config{}, fmt.Errorf("failed to fetch %s from github: status %d", opts.Path, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return config.Config{}, fmt.Errorf("failed to read github file: %w", err)
+	}
+	return config.ParseConfig(data, opts.Path)
+}
+
+// escapePath escapes each segment of a slash-separated path
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
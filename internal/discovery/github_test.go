@@ -0,0 +1,50 @@
+package discovery
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andre/ssl-cert-test/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchGitHubConfig(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer gh-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, "application/vnd.github.raw+json", r.Header.Get("Accept"))
+		assert.Equal(t, "/repos/acme/infra/contents/certs/targets.yaml", r.URL.Path)
+		assert.Equal(t, "release", r.URL.Query().Get("ref"))
+
+		fmt.Fprint(w, "domains:\n  - example.com\ntargets:\n  - host: mail.example.com\n    protocol: smtp\n    ports: [25]\n")
+	}))
+	defer ts.Close()
+
+	originalURL := GitHubBaseURL
+	GitHubBaseURL = ts.URL
+	defer func() { GitHubBaseURL = originalURL }()
+
+	conf, err := FetchGitHubConfig(GitHubOptions{Token: "gh-token", Repo: "acme/infra", Path: "certs/targets.yaml", Ref: "release"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"example.com"}, conf.Domains)
+	assert.Equal(t, []config.Target{{Host: "mail.example.com", Protocol: "smtp", Ports: []int{25}}}, conf.Targets)
+	assert.Equal(t, config.DefaultPorts, conf.Ports, "the local config parser fills in defaults")
+}
+
+func TestFetchGitHubConfig_Errors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	_, err := FetchGitHubConfig(GitHubOptions{Repo: "acme/infra", Path: "missing.json", BaseURL: ts.URL})
+	assert.ErrorContains(t, err, "status 404")
+
+	_, err = FetchGitHubConfig(GitHubOptions{Repo: "infra", Path: "targets.json"})
+	assert.ErrorContains(t, err, "owner/name")
+}
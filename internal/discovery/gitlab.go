@@ -2,13 +2,31 @@ package discovery
 
 import (
 	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+
 	"github.com/andre/ssl-cert-test/internal/config"
 	"gitlab.com/gitlab-org/api/client-go"
 )
 
-// FetchGitLabConfig retrieves and parses a JSON config file from a GitLab repository
+// GitLabOptions selects the target files to read: paths or globs (e.g.
+// "targets/**/*.yaml") in one project, and/or a conventional file name in
+// every project of a group so each team can own its list in its own repo
+type GitLabOptions struct {
+	Token     string
+	URL       string
+	ProjectID string
+	FilePaths []string
+	Ref       string // Branch or commit; empty uses each group project's default branch
+	Group     string
+	GroupFile string
+	Ports     []int // The run's -ports, added to each file's own port list
+}
+
+// FetchGitLabConfig retrieves and parses a JSON or YAML config file from a GitLab repository
 func FetchGitLabConfig(token, baseURL, projectID, filePath, ref string) (config.Config, error) {
 	var conf config.Config
 
@@ -18,22 +36,223 @@ func FetchGitLabConfig(token, baseURL, projectID, filePath, ref string) (config.
 		return conf, fmt.Errorf("failed to create gitlab client: %w", err)
 	}
 
+	return fetchGitLabFile(gl, projectID, filePath, ref)
+}
+
+// FetchGitLabTargets reads every selected file and merges them. Targets are
+// labelled with the project and file they came from; plain domains become
+// targets so they carry those labels too.
+func FetchGitLabTargets(opts GitLabOptions) (config.Config, error) {
+	var merged config.Config
+
+	if opts.ProjectID == "" && opts.Group == "" {
+		return merged, fmt.Errorf("a gitlab project or group is required")
+	}
+
+	gl, err := gitlab.NewClient(opts.Token, gitlab.WithBaseURL(opts.URL))
+	if err != nil {
+		return merged, fmt.Errorf("failed to create gitlab client: %w", err)
+	}
+
+	if opts.ProjectID != "" {
+		if len(opts.FilePaths) == 0 {
+			return merged, fmt.Errorf("gitlab file path is required")
+		}
+
+		var files []string
+		for _, p := range opts.FilePaths {
+			if !isGlob(p) {
+				files = append(files, p)
+				continue
+			}
+			matches, err := listGitLabFiles(gl, opts.ProjectID, p, opts.Ref)
+			if err != nil {
+				return merged, err
+			}
+			if len(matches) == 0 {
+				slog.Warn("gitlab glob matched no files", "project", opts.ProjectID, "pattern", p)
+			}
+			files = append(files, matches...)
+		}
+
+		for _, file := range files {
+			conf, err := fetchGitLabFile(gl, opts.ProjectID, file, opts.Ref)
+			if err != nil {
+				return merged, fmt.Errorf("%s: %w", file, err)
+			}
+			mergeGitLabConfig(&merged, conf, opts.ProjectID, file, opts.Ports)
+		}
+	}
+
+	if opts.Group != "" {
+		if err := fetchGitLabGroup(gl, opts, &merged); err != nil {
+			return merged, err
+		}
+	}
+
+	return merged, nil
+}
+
+// fetchGitLabGroup reads GroupFile from every active project of the group and
+// its subgroups. Projects without the file are skipped; other failures become
+// error rows so one broken repo does not hide the rest.
+func fetchGitLabGroup(gl *gitlab.Client, opts GitLabOptions, merged *config.Config) error {
+	if opts.GroupFile == "" {
+		return fmt.Errorf("gitlab group file name is required")
+	}
+
+	listOpts := &gitlab.ListGroupProjectsOptions{
+		ListOptions:      gitlab.ListOptions{PerPage: 100},
+		IncludeSubGroups: gitlab.Ptr(true),
+		Archived:         gitlab.Ptr(false),
+	}
+	for {
+		projects, resp, err := gl.Groups.ListGroupProjects(opts.Group, listOpts)
+		if err != nil {
+			return fmt.Errorf("failed to list gitlab group projects: %w", err)
+		}
+
+		for _, p := range projects {
+			ref := opts.Ref
+			if ref == "" {
+				ref = p.DefaultBranch
+			}
+			if ref == "" {
+				continue // Empty repository
+			}
+
+			conf, err := fetchGitLabFile(gl, p.ID, opts.GroupFile, ref)
+			if errors.Is(err, gitlab.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				slog.Warn("gitlab project skipped", "project", p.PathWithNamespace, "error", err)
+				labels := map[string]string{"gitlab_project": p.PathWithNamespace, "gitlab_file": opts.GroupFile}
+				merged.Results = append(merged.Results, discoveryError("gitlab", p.PathWithNamespace, labels, err))
+				continue
+			}
+			mergeGitLabConfig(merged, conf, p.PathWithNamespace, opts.GroupFile, opts.Ports)
+		}
+
+		if resp.NextPage == 0 {
+			return nil
+		}
+		listOpts.Page = resp.NextPage
+	}
+}
+
+// listGitLabFiles returns the repository files matching a glob, walking the
+// tree below the glob's fixed leading directories
+func listGitLabFiles(gl *gitlab.Client, projectID, pattern, ref string) ([]string, error) {
+	treeOpts := &gitlab.ListTreeOptions{
+		ListOptions: gitlab.ListOptions{PerPage: 100},
+		Recursive:   gitlab.Ptr(true),
+	}
+	if dir := globBaseDir(pattern); dir != "" {
+		treeOpts.Path = gitlab.Ptr(dir)
+	}
+	if ref != "" {
+		treeOpts.Ref = gitlab.Ptr(ref)
+	}
+
+	var files []string
+	for {
+		nodes, resp, err := gl.Repositories.ListTree(projectID, treeOpts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list gitlab repository tree: %w", err)
+		}
+		for _, n := range nodes {
+			if n.Type == "blob" && matchPathGlob(pattern, n.Path) {
+				files = append(files, n.Path)
+			}
+		}
+
+		if resp.NextPage == 0 {
+			return files, nil
+		}
+		treeOpts.Page = resp.NextPage
+	}
+}
+
+func fetchGitLabFile(gl *gitlab.Client, projectID interface{}, filePath, ref string) (config.Config, error) {
 	// Fetch the file
 	file, _, err := gl.RepositoryFiles.GetFile(projectID, filePath, &gitlab.GetFileOptions{Ref: &ref})
 	if err != nil {
-		return conf, fmt.Errorf("failed to fetch file from gitlab: %w", err)
+		return config.Config{}, fmt.Errorf("failed to fetch file from gitlab: %w", err)
 	}
 
 	// GitLab API returns content as Base64 encoded string
 	decoded, err := base64.StdEncoding.DecodeString(file.Content)
 	if err != nil {
-		return conf, fmt.Errorf("failed to decode gitlab file content: %w", err)
+		return config.Config{}, fmt.Errorf("failed to decode gitlab file content: %w", err)
+	}
+
+	return config.DecodeConfig(decoded, filePath)
+}
+
+// mergeGitLabConfig adds one file's targets to merged, labelled with their
+// origin. The file's ports, plus the run's -ports, apply to its own targets
+// only, not to other files'.
+func mergeGitLabConfig(merged *config.Config, conf config.Config, project, file string, cliPorts []int) {
+	merged.Cidr = append(merged.Cidr, conf.Cidr...)
+
+	for _, t := range conf.AllTargets() {
+		if len(t.Ports) == 0 && len(conf.Ports) > 0 {
+			t.Ports = config.MergePorts(conf.Ports, cliPorts)
+		}
+		labels := make(map[string]string, len(t.Labels)+2)
+		for k, v := range t.Labels {
+			labels[k] = v
+		}
+		labels["gitlab_project"] = project
+		labels["gitlab_file"] = file
+		t.Labels = labels
+		merged.Targets = append(merged.Targets, t)
 	}
+}
+
+func isGlob(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
 
-	// Unmarshal the JSON content into our Config struct
-	if err := json.Unmarshal(decoded, &conf); err != nil {
-		return conf, fmt.Errorf("failed to parse json config: %w", err)
+// globBaseDir returns the leading directories of a glob that contain no wildcards
+func globBaseDir(pattern string) string {
+	var fixed []string
+	for _, seg := range strings.Split(pattern, "/") {
+		if isGlob(seg) {
+			break
+		}
+		fixed = append(fixed, seg)
+	}
+	if len(fixed) == len(strings.Split(pattern, "/")) {
+		fixed = fixed[:len(fixed)-1]
 	}
+	return strings.Join(fixed, "/")
+}
 
-	return conf, nil
+// matchPathGlob matches a slash-separated path against a glob where "**"
+// stands for any number of directories
+func matchPathGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
 }
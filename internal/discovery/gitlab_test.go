@@ -6,6 +6,9 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/andre/ssl-cert-test/internal/config"
@@ -117,3 +120,160 @@ func TestFetchGitLabConfig_Error_BadJSON(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to parse json")
 }
+
+// fakeGitLab serves repository files and trees from an in-memory map of
+// "project:path" to content
+func fakeGitLab(t *testing.T, files map[string]string, projects []map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := strings.TrimPrefix(r.URL.Path, "/api/v4/")
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case strings.HasPrefix(p, "groups/platform/projects"):
+			assert.Equal(t, "true", r.URL.Query().Get("include_subgroups"))
+			json.NewEncoder(w).Encode(projects)
+		case strings.Contains(p, "/repository/tree"):
+			project := strings.Split(strings.TrimPrefix(p, "projects/"), "/")[0]
+			prefix := r.URL.Query().Get("path")
+			// Serve one file per page to exercise pagination
+			var nodes []map[string]string
+			for key := range files {
+				proj, path, _ := strings.Cut(key, ":")
+				if proj == project && strings.HasPrefix(path, prefix+"/") {
+					nodes = append(nodes, map[string]string{"type": "blob", "path": path})
+				}
+			}
+			sort.Slice(nodes, func(i, j int) bool { return nodes[i]["path"] < nodes[j]["path"] })
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			if page == 0 {
+				page = 1
+			}
+			if page < len(nodes) {
+				w.Header().Set("X-Next-Page", strconv.Itoa(page+1))
+			}
+			json.NewEncoder(w).Encode(nodes[page-1 : page])
+		case strings.Contains(p, "/repository/files/"):
+			project, path, _ := strings.Cut(strings.TrimPrefix(p, "projects/"), "/repository/files/")
+			content, ok := files[project+":"+path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"message":"404 File Not Found"}`)
+				return
+			}
+			if content == "forbidden" {
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprint(w, `{"message":"403 Forbidden"}`)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"file_path": path,
+				"content":   base64.StdEncoding.EncodeToString([]byte(content)),
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestFetchGitLabConfig_YAML(t *testing.T) {
+	ts := fakeGitLab(t, map[string]string{
+		"123:targets.yml": "version: 2\nports: [443]\ntargets:\n  - host: mail.example.com\n    protocol: smtp\n    ports: [25]\n",
+	}, nil)
+	defer ts.Close()
+
+	conf, err := FetchGitLabConfig("token", ts.URL, "123", "targets.yml", "main")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []int{443}, conf.Ports)
+	assert.Equal(t, []config.Target{{Host: "mail.example.com", Protocol: "smtp", Ports: []int{25}}}, conf.Targets)
+}
+
+func TestFetchGitLabTargets_FilesAndGlobs(t *testing.T) {
+	ts := fakeGitLab(t, map[string]string{
+		"123:config.json":              `{"ports": [443], "domains": ["root.example.com"]}`,
+		"123:targets/web.yaml":         "domains: [www.example.com]\n",
+		"123:targets/prod/api.yaml":    "ports: [8443]\ntargets:\n  - host: api.example.com\n    labels: {env: prod}\n",
+		"123:targets/prod/README.md":   "not a target file",
+		"123:targets/staging/api.json": `{"domains": ["api.staging.example.com"]}`,
+	}, nil)
+	defer ts.Close()
+
+	conf, err := FetchGitLabTargets(GitLabOptions{
+		Token:     "token",
+		URL:       ts.URL,
+		ProjectID: "123",
+		FilePaths: []string{"config.json", "targets/**/*.yaml"},
+		Ref:       "main",
+	})
+
+	assert.NoError(t, err)
+	assert.Empty(t, conf.Ports, "each file's ports stay with its own targets")
+
+	var hosts []string
+	for _, target := range conf.Targets {
+		hosts = append(hosts, target.Host)
+		assert.Equal(t, "123", target.Labels["gitlab_project"])
+	}
+	assert.Equal(t, []string{"root.example.com", "api.example.com", "www.example.com"}, hosts)
+	assert.Equal(t, "config.json", conf.Targets[0].Labels["gitlab_file"])
+	assert.Equal(t, "targets/prod/api.yaml", conf.Targets[1].Labels["gitlab_file"])
+	assert.Equal(t, "prod", conf.Targets[1].Labels["env"])
+	assert.Equal(t, []int{443}, conf.Targets[0].Ports)
+	assert.Equal(t, []int{8443}, conf.Targets[1].Ports)
+	assert.Empty(t, conf.Targets[2].Ports, "a file without ports uses the global list")
+}
+
+func TestFetchGitLabTargets_CLIPorts(t *testing.T) {
+	ts := fakeGitLab(t, map[string]string{
+		"123:config.json": `{"ports": [443], "domains": ["www.example.com"], "targets": [{"host": "mail.example.com", "ports": [25]}]}`,
+	}, nil)
+	defer ts.Close()
+
+	provider, err := GetProvider(&config.AppConfig{
+		ConfigType:      "gitlab",
+		GitlabURL:       ts.URL,
+		GitlabProjectID: "123",
+		GitlabFilePath:  "config.json",
+		PortString:      "8443",
+	})
+	assert.NoError(t, err)
+
+	conf, err := provider.FetchTargets()
+	assert.NoError(t, err)
+	assert.Equal(t, []int{443, 8443}, conf.Targets[0].Ports, "-ports still applies to a single file's targets")
+	assert.Equal(t, []int{25}, conf.Targets[1].Ports, "targets keep their own ports")
+}
+
+func TestFetchGitLabTargets_Group(t *testing.T) {
+	ts := fakeGitLab(t, map[string]string{
+		"7:ssl-targets.yaml": "domains: [a.example.com]\n",
+		"9:ssl-targets.yaml": "forbidden",
+	}, []map[string]interface{}{
+		{"id": 7, "path_with_namespace": "platform/team-a", "default_branch": "main"},
+		{"id": 8, "path_with_namespace": "platform/team-b", "default_branch": "main"},
+		{"id": 9, "path_with_namespace": "platform/sub/team-c", "default_branch": "trunk"},
+		{"id": 10, "path_with_namespace": "platform/empty"},
+	})
+	defer ts.Close()
+
+	conf, err := FetchGitLabTargets(GitLabOptions{URL: ts.URL, Group: "platform", GroupFile: "ssl-targets.yaml"})
+
+	assert.NoError(t, err)
+	assert.Len(t, conf.Targets, 1, "projects without the file are skipped")
+	assert.Equal(t, "a.example.com", conf.Targets[0].Host)
+	assert.Equal(t, "platform/team-a", conf.Targets[0].Labels["gitlab_project"])
+	assert.Equal(t, "ssl-targets.yaml", conf.Targets[0].Labels["gitlab_file"])
+
+	assert.Len(t, conf.Results, 1)
+	assert.Equal(t, "platform/sub/team-c", conf.Results[0].Domain)
+	assert.Equal(t, "gitlab", conf.Results[0].Source)
+}
+
+func TestMatchPathGlob(t *testing.T) {
+	assert.True(t, matchPathGlob("targets/**/*.yaml", "targets/a.yaml"))
+	assert.True(t, matchPathGlob("targets/**/*.yaml", "targets/x/y/a.yaml"))
+	assert.False(t, matchPathGlob("targets/**/*.yaml", "other/a.yaml"))
+	assert.False(t, matchPathGlob("targets/*.yaml", "targets/x/a.yaml"))
+	assert.Equal(t, "targets", globBaseDir("targets/**/*.yaml"))
+	assert.Equal(t, "", globBaseDir("*.yaml"))
+}
@@ -0,0 +1,131 @@
+package discovery
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/andre/ssl-cert-test/internal/config"
+)
+
+// HTTPConfigOptions fetches a target file from any http(s) URL. Bearer auth
+// wins over basic auth when both are set.
+type HTTPConfigOptions struct {
+	URL         string
+	BearerToken string
+	Username    string
+	Password    string
+	CacheDir    string // Where the last response and its ETag are kept; empty uses the user cache dir
+}
+
+// httpCacheEntry is the cached copy of a config URL
+type httpCacheEntry struct {
+	ETag string `json:"etag"`
+	Body []byte `json:"body"`
+}
+
+// FetchHTTPConfig downloads a JSON or YAML target file and parses it like a
+// local config file. The response is cached with its ETag, so unchanged files
+// are answered with 304 Not Modified and served from the cache.
+func FetchHTTPConfig(opts HTTPConfigOptions) (config.Config, error) {
+	if opts.URL == "" {
+		return config.Config{}, fmt.Errorf("config url is required")
+	}
+	u, err := url.Parse(opts.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return config.Config{}, fmt.Errorf("invalid config url: %s", opts.URL)
+	}
+
+	req, err := http.NewRequest("GET", opts.URL, nil)
+	if err != nil {
+		return config.Config{}, fmt.Errorf("invalid config url: %w", err)
+	}
+	if opts.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.BearerToken)
+	} else if opts.Username != "" || opts.Password != "" {
+		req.SetBasicAuth(opts.Username, opts.Password)
+	}
+
+	cachePath := httpCachePath(opts)
+	cached := readHTTPCache(cachePath)
+	if cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return config.Config{}, fmt.Errorf("failed to fetch config url: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var data []byte
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		slog.Debug("config url not modified, using cached copy", "url", opts.URL)
+		data = cached.Body
+	case http.StatusOK:
+		if data, err = io.ReadAll(resp.Body); err != nil {
+			return config.Config{}, fmt.Errorf("failed to read config url: %w", err)
+		}
+		if etag := resp.Header.Get("ETag"); etag != "" && cachePath != "" {
+			writeHTTPCache(cachePath, httpCacheEntry{ETag: etag, Body: data})
+		}
+	default:
+		return config.Config{}, fmt.Errorf("failed to fetch config url %s: status %d", opts.URL, resp.StatusCode)
+	}
+
+	return config.ParseConfig(data, u.Path)
+}
+
+// httpCachePath returns the cache file of a URL, or "" when there is no cache dir
+func httpCachePath(opts HTTPConfigOptions) string {
+	dir := opts.CacheDir
+	if dir == "" {
+		userDir, err := os.UserCacheDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(userDir, "ssl_cert_checker")
+	}
+	sum := sha256.Sum256([]byte(opts.URL))
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func readHTTPCache(path string) httpCacheEntry {
+	var entry httpCacheEntry
+	if path == "" {
+		return entry
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return entry
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return httpCacheEntry{}
+	}
+	return entry
+}
+
+// writeHTTPCache is best effort: a read-only cache only costs a full download
+func writeHTTPCache(path string, entry httpCacheEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		slog.Warn("failed to create config cache dir", "error", err)
+		return
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		slog.Warn("failed to write config cache", "error", err)
+	}
+}
@@ -0,0 +1,60 @@
+package discovery
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchHTTPConfig_ETagCache(t *testing.T) {
+	var requests, downloads int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if user, pass, ok := r.BasicAuth(); !ok || user != "ci" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, `{"ports": [443], "domains": ["example.com"]}`)
+	}))
+	defer ts.Close()
+
+	opts := HTTPConfigOptions{URL: ts.URL + "/targets.json", Username: "ci", Password: "secret", CacheDir: t.TempDir()}
+
+	for i := 0; i < 2; i++ {
+		conf, err := FetchHTTPConfig(opts)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"example.com"}, conf.Domains)
+	}
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, 1, downloads, "the second fetch is answered from the cache")
+}
+
+func TestFetchHTTPConfig_BearerAndYAML(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, "cidr: [10.0.0.0/30]\n")
+	}))
+	defer ts.Close()
+
+	conf, err := FetchHTTPConfig(HTTPConfigOptions{URL: ts.URL + "/targets.yaml?version=2", BearerToken: "tok", CacheDir: t.TempDir()})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.0/30"}, conf.Cidr)
+
+	_, err = FetchHTTPConfig(HTTPConfigOptions{URL: ts.URL + "/targets.yaml", CacheDir: t.TempDir()})
+	assert.ErrorContains(t, err, "status 403")
+
+	_, err = FetchHTTPConfig(HTTPConfigOptions{URL: "ftp://example.com/targets.json"})
+	assert.ErrorContains(t, err, "invalid config url")
+}
@@ -0,0 +1,213 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/andre/ssl-cert-test/internal/config"
+	"github.com/andre/ssl-cert-test/internal/scan"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// GatewayGVR is the Gateway API resource listed through the dynamic client
+var GatewayGVR = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "gateways"}
+
+// KubernetesOptions selects the cluster and namespaces to walk
+type KubernetesOptions struct {
+	Kubeconfig string   // Empty uses in-cluster credentials, then the default kubeconfig
+	Context    string   // Kubeconfig context; empty uses the current one
+	Namespaces []string // Empty walks every namespace
+}
+
+// KubernetesClientCreatorFunc is a variable we can swap in tests to return fake clients
+var KubernetesClientCreatorFunc = func(opts KubernetesOptions) (kubernetes.Interface, dynamic.Interface, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = opts.Kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: opts.Context}
+
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load kubernetes config: %w", err)
+	}
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	dyn, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create kubernetes dynamic client: %w", err)
+	}
+	return clientset, dyn, nil
+}
+
+// FetchFromKubernetes inventories kubernetes.io/tls secrets as result rows and turns
+// Ingress TLS hosts and Gateway HTTPS/TLS listeners into handshake targets
+func FetchFromKubernetes(opts KubernetesOptions, now time.Time) ([]config.Target, []config.DomainValidity, error) {
+	clientset, dyn, err := KubernetesClientCreatorFunc(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	namespaces := opts.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+
+	var targets []config.Target
+	var results []config.DomainValidity
+	ctx := context.Background()
+
+	for _, ns := range namespaces {
+		found, err := kubernetesSecretResults(ctx, clientset, ns, now)
+		if err != nil {
+			return nil, nil, err
+		}
+		results = append(results, found...)
+
+		ingressTargets, err := kubernetesIngressTargets(ctx, clientset, ns)
+		if err != nil {
+			return nil, nil, err
+		}
+		targets = append(targets, ingressTargets...)
+
+		gatewayTargets, err := kubernetesGatewayTargets(ctx, dyn, ns)
+		if err != nil {
+			return nil, nil, err
+		}
+		targets = append(targets, gatewayTargets...)
+	}
+
+	return targets, results, nil
+}
+
+func kubernetesLabels(kind string, obj metav1.Object) map[string]string {
+	l := map[string]string{
+		"k8s_namespace": obj.GetNamespace(),
+		"k8s_kind":      kind,
+		"k8s_name":      obj.GetName(),
+	}
+	if owners := obj.GetOwnerReferences(); len(owners) > 0 {
+		l["k8s_owner"] = owners[0].Kind + "/" + owners[0].Name
+	}
+	return l
+}
+
+// kubernetesSecretResults parses the leaf certificate of every TLS secret
+func kubernetesSecretResults(ctx context.Context, clientset kubernetes.Interface, ns string, now time.Time) ([]config.DomainValidity, error) {
+	secrets, err := clientset.CoreV1().Secrets(ns).List(ctx, metav1.ListOptions{
+		FieldSelector: "type=" + string(corev1.SecretTypeTLS),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tls secrets: %w", err)
+	}
+
+	var results []config.DomainValidity
+	for _, secret := range secrets.Items {
+		if secret.Type != corev1.SecretTypeTLS {
+			continue
+		}
+		labels := kubernetesLabels("Secret", &secret)
+
+		certs, err := scan.ParsePEMCertificates(secret.Data[corev1.TLSCertKey])
+		if err != nil {
+			subject := fmt.Sprintf("secret/%s/%s", secret.Namespace, secret.Name)
+			results = append(results, discoveryError("kubernetes-secret", subject, labels, err))
+			continue
+		}
+
+		result := scan.CertificateResult("kubernetes-secret", "", certs[0], now)
+		result.Labels = labels
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// kubernetesIngressTargets returns the TLS hosts of every Ingress, dialled at the
+// load balancer address when the controller has published one
+func kubernetesIngressTargets(ctx context.Context, clientset kubernetes.Interface, ns string) ([]config.Target, error) {
+	ingresses, err := clientset.NetworkingV1().Ingresses(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ingresses: %w", err)
+	}
+
+	var targets []config.Target
+	for _, ing := range ingresses.Items {
+		address := ""
+		if lb := ing.Status.LoadBalancer.Ingress; len(lb) > 0 {
+			address = lb[0].IP
+			if address == "" {
+				address = lb[0].Hostname
+			}
+		}
+
+		for _, tls := range ing.Spec.TLS {
+			for _, host := range tls.Hosts {
+				if host == "" || strings.HasPrefix(host, "*.") {
+					continue
+				}
+				labels := kubernetesLabels("Ingress", &ing)
+				labels["k8s_tls_secret"] = tls.SecretName
+				targets = append(targets, config.Target{Host: config.NormalizeHost(host), Address: address, Labels: labels})
+			}
+		}
+	}
+	return targets, nil
+}
+
+// kubernetesGatewayTargets returns one target per HTTPS/TLS Gateway listener hostname.
+// Clusters without the Gateway API CRDs are skipped.
+func kubernetesGatewayTargets(ctx context.Context, dyn dynamic.Interface, ns string) ([]config.Target, error) {
+	gateways, err := dyn.Resource(GatewayGVR).Namespace(ns).List(ctx, metav1.ListOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list gateways: %w", err)
+	}
+
+	var targets []config.Target
+	for _, gw := range gateways.Items {
+		address := ""
+		addresses, _, _ := unstructured.NestedSlice(gw.Object, "status", "addresses")
+		if len(addresses) > 0 {
+			if a, ok := addresses[0].(map[string]interface{}); ok {
+				address, _ = a["value"].(string)
+			}
+		}
+
+		listeners, _, _ := unstructured.NestedSlice(gw.Object, "spec", "listeners")
+		for _, item := range listeners {
+			listener, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			protocol, _ := listener["protocol"].(string)
+			hostname, _ := listener["hostname"].(string)
+			if (protocol != "HTTPS" && protocol != "TLS") || hostname == "" || strings.HasPrefix(hostname, "*.") {
+				continue
+			}
+			port, _, _ := unstructured.NestedInt64(listener, "port")
+			name, _ := listener["name"].(string)
+
+			labels := kubernetesLabels("Gateway", &gw)
+			labels["k8s_listener"] = name
+
+			target := config.Target{Host: config.NormalizeHost(hostname), Address: address, Labels: labels}
+			if port > 0 {
+				target.Ports = []int{int(port)}
+			}
+			targets = append(targets, target)
+		}
+	}
+	return targets, nil
+}
@@ -0,0 +1,110 @@
+package discovery
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestFetchFromKubernetes(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+
+	clientset := fake.NewSimpleClientset(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "shop-tls", Namespace: "shop",
+				OwnerReferences: []metav1.OwnerReference{{Kind: "Certificate", Name: "shop"}},
+			},
+			Type: corev1.SecretTypeTLS,
+			Data: map[string][]byte{corev1.TLSCertKey: testCertPEM(t, "shop.example.com", []string{"shop.example.com"}, now.Add(15*24*time.Hour))},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "broken-tls", Namespace: "shop"},
+			Type:       corev1.SecretTypeTLS,
+			Data:       map[string][]byte{corev1.TLSCertKey: []byte("not a certificate")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-password", Namespace: "shop"},
+			Type:       corev1.SecretTypeOpaque,
+		},
+		&networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "shop"},
+			Spec: networkingv1.IngressSpec{
+				TLS: []networkingv1.IngressTLS{{Hosts: []string{"shop.example.com", "*.shop.example.com"}, SecretName: "shop-tls"}},
+			},
+			Status: networkingv1.IngressStatus{LoadBalancer: networkingv1.IngressLoadBalancerStatus{
+				Ingress: []networkingv1.IngressLoadBalancerIngress{{IP: "10.1.2.3"}},
+			}},
+		},
+	)
+
+	gateway := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "gateway.networking.k8s.io/v1",
+		"kind":       "Gateway",
+		"metadata":   map[string]interface{}{"name": "edge", "namespace": "shop"},
+		"spec": map[string]interface{}{
+			"listeners": []interface{}{
+				map[string]interface{}{"name": "https", "protocol": "HTTPS", "port": int64(8443), "hostname": "api.example.com"},
+				map[string]interface{}{"name": "http", "protocol": "HTTP", "port": int64(80), "hostname": "api.example.com"},
+			},
+		},
+		"status": map[string]interface{}{
+			"addresses": []interface{}{map[string]interface{}{"type": "IPAddress", "value": "10.9.9.9"}},
+		},
+	}}
+	dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{GatewayGVR: "GatewayList"})
+	if err := dyn.Tracker().Create(GatewayGVR, gateway, "shop"); err != nil {
+		t.Fatal(err)
+	}
+
+	originalCreator := KubernetesClientCreatorFunc
+	KubernetesClientCreatorFunc = func(opts KubernetesOptions) (kubernetes.Interface, dynamic.Interface, error) {
+		return clientset, dyn, nil
+	}
+	defer func() { KubernetesClientCreatorFunc = originalCreator }()
+
+	targets, results, err := FetchFromKubernetes(KubernetesOptions{Namespaces: []string{"shop"}}, now)
+
+	assert.NoError(t, err)
+
+	// Secrets: one parsed certificate and one error row, opaque secrets ignored
+	assert.Len(t, results, 2)
+	var parsed, broken = results[0], results[1]
+	if parsed.Error != "" {
+		parsed, broken = broken, parsed
+	}
+	assert.Equal(t, "kubernetes-secret", parsed.Source)
+	assert.Equal(t, "shop.example.com", parsed.Domain)
+	assert.Equal(t, 15, parsed.DaysUntilExpiry)
+	assert.Equal(t, "shop", parsed.Labels["k8s_namespace"])
+	assert.Equal(t, "Certificate/shop", parsed.Labels["k8s_owner"])
+	assert.Equal(t, "secret/shop/broken-tls", broken.Domain)
+	assert.NotEmpty(t, broken.Error)
+
+	// Ingress TLS hosts (wildcards skipped) and HTTPS gateway listeners
+	assert.Len(t, targets, 2)
+	ing := targets[0]
+	assert.Equal(t, "shop.example.com", ing.Host)
+	assert.Equal(t, "10.1.2.3", ing.Address)
+	assert.Equal(t, "Ingress", ing.Labels["k8s_kind"])
+	assert.Equal(t, "shop-tls", ing.Labels["k8s_tls_secret"])
+
+	gw := targets[1]
+	assert.Equal(t, "api.example.com", gw.Host)
+	assert.Equal(t, "10.9.9.9", gw.Address)
+	assert.Equal(t, []int{8443}, gw.Ports)
+	assert.Equal(t, "Gateway", gw.Labels["k8s_kind"])
+	assert.Equal(t, "https", gw.Labels["k8s_listener"])
+}
@@ -0,0 +1,156 @@
+package discovery
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andre/ssl-cert-test/internal/config"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudfront"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+)
+
+// FetchTargetsFromAWSListeners turns every ELBv2 HTTPS/TLS listener of the regions
+// into a target on the listener's port, and every CloudFront alternate domain name
+// into a target dialled at its distribution. With a role set in accounts, each
+// account is visited and failures are returned as error rows.
+func FetchTargetsFromAWSListeners(regions []string, accounts AWSAccounts) ([]config.Target, []config.DomainValidity, error) {
+	if accounts.RoleName == "" {
+		sess, err := newAWSSession()
+		if err != nil {
+			return nil, nil, err
+		}
+		targets, err := awsListenerTargets(sess, regions)
+		return targets, nil, err
+	}
+
+	var targets []config.Target
+	failures, err := forEachAWSAccount(accounts, "aws-listeners", func(accountID string, sess *session.Session) error {
+		found, err := awsListenerTargets(sess, regions)
+		if err != nil {
+			return err
+		}
+		for _, t := range found {
+			t.Labels["aws_account"] = accountID
+			targets = append(targets, t)
+		}
+		return nil
+	})
+
+	return targets, failures, err
+}
+
+func awsListenerTargets(sess *session.Session, regions []string) ([]config.Target, error) {
+	regions, err := awsRegions(sess, regions)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []config.Target
+	for _, region := range regions {
+		found, err := listELBListeners(elbv2.New(sess, aws.NewConfig().WithRegion(region)), region)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, found...)
+	}
+
+	// CloudFront is global, so distributions are listed once per account
+	found, err := listCloudFrontAliases(cloudfront.New(sess))
+	if err != nil {
+		return nil, err
+	}
+	return append(targets, found...), nil
+}
+
+// listELBListeners returns one target per HTTPS/TLS listener, internal load balancers included
+func listELBListeners(svc *elbv2.ELBV2, region string) ([]config.Target, error) {
+	var lbs []*elbv2.LoadBalancer
+	err := svc.DescribeLoadBalancersPages(&elbv2.DescribeLoadBalancersInput{}, func(page *elbv2.DescribeLoadBalancersOutput, lastPage bool) bool {
+		lbs = append(lbs, page.LoadBalancers...)
+		return !lastPage
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe load balancers in %s: %v", region, err)
+	}
+
+	var targets []config.Target
+	for _, lb := range lbs {
+		var listeners []*elbv2.Listener
+		err := svc.DescribeListenersPages(&elbv2.DescribeListenersInput{LoadBalancerArn: lb.LoadBalancerArn}, func(page *elbv2.DescribeListenersOutput, lastPage bool) bool {
+			listeners = append(listeners, page.Listeners...)
+			return !lastPage
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe listeners of %s: %v", aws.StringValue(lb.LoadBalancerName), err)
+		}
+
+		for _, l := range listeners {
+			protocol := aws.StringValue(l.Protocol)
+			if protocol != elbv2.ProtocolEnumHttps && protocol != elbv2.ProtocolEnumTls {
+				continue
+			}
+
+			var certARNs []string
+			for _, c := range l.Certificates {
+				certARNs = append(certARNs, aws.StringValue(c.CertificateArn))
+			}
+
+			targets = append(targets, config.Target{
+				Host:  config.NormalizeHost(aws.StringValue(lb.DNSName)),
+				Ports: []int{int(aws.Int64Value(l.Port))},
+				Labels: map[string]string{
+					"aws_region":    region,
+					"elb_name":      aws.StringValue(lb.LoadBalancerName),
+					"elb_type":      aws.StringValue(lb.Type),
+					"elb_scheme":    aws.StringValue(lb.Scheme),
+					"elb_protocol":  protocol,
+					"elb_cert_arns": strings.Join(certARNs, ","),
+				},
+			})
+		}
+	}
+	return targets, nil
+}
+
+// listCloudFrontAliases returns one target per alternate domain name, using the alias
+// as SNI against the distribution's own hostname so DNS drift does not hide it
+func listCloudFrontAliases(svc *cloudfront.CloudFront) ([]config.Target, error) {
+	var targets []config.Target
+	err := svc.ListDistributionsPages(&cloudfront.ListDistributionsInput{}, func(page *cloudfront.ListDistributionsOutput, lastPage bool) bool {
+		if page.DistributionList == nil {
+			return false
+		}
+		for _, d := range page.DistributionList.Items {
+			if d.Aliases == nil {
+				continue
+			}
+			certARN := ""
+			if vc := d.ViewerCertificate; vc != nil {
+				certARN = aws.StringValue(vc.ACMCertificateArn)
+				if certARN == "" {
+					certARN = aws.StringValue(vc.IAMCertificateId)
+				}
+			}
+
+			for _, alias := range d.Aliases.Items {
+				targets = append(targets, config.Target{
+					Host:    config.NormalizeHost(aws.StringValue(alias)),
+					Address: aws.StringValue(d.DomainName),
+					Ports:   []int{443},
+					Labels: map[string]string{
+						"cloudfront_id":       aws.StringValue(d.Id),
+						"cloudfront_domain":   aws.StringValue(d.DomainName),
+						"cloudfront_cert_arn": certARN,
+					},
+				})
+			}
+		}
+		return !lastPage
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cloudfront distributions: %v", err)
+	}
+	return targets, nil
+}
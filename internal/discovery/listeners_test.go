@@ -0,0 +1,68 @@
+package discovery
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeListeners serves ELBv2 (query protocol) and CloudFront (REST XML) calls
+func fakeListeners(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+
+		if strings.HasSuffix(r.URL.Path, "/distribution") {
+			fmt.Fprint(w, `<DistributionList><Marker></Marker><MaxItems>100</MaxItems><IsTruncated>false</IsTruncated><Quantity>2</Quantity><Items>
+<DistributionSummary><Id>E1</Id><DomainName>d111.cloudfront.net</DomainName>
+  <Aliases><Quantity>2</Quantity><Items><CNAME>cdn.example.com</CNAME><CNAME>static.example.com</CNAME></Items></Aliases>
+  <ViewerCertificate><ACMCertificateArn>arn:aws:acm:us-east-1:1:certificate/cdn</ACMCertificateArn></ViewerCertificate></DistributionSummary>
+<DistributionSummary><Id>E2</Id><DomainName>d222.cloudfront.net</DomainName><Aliases><Quantity>0</Quantity></Aliases></DistributionSummary>
+</Items></DistributionList>`)
+			return
+		}
+
+		r.ParseForm()
+		switch r.Form.Get("Action") {
+		case "DescribeLoadBalancers":
+			fmt.Fprint(w, `<DescribeLoadBalancersResponse><DescribeLoadBalancersResult><LoadBalancers>
+<member><LoadBalancerArn>arn:lb/internal-api</LoadBalancerArn><LoadBalancerName>internal-api</LoadBalancerName><DNSName>internal-api-1.us-east-1.elb.amazonaws.com</DNSName><Type>network</Type><Scheme>internal</Scheme></member>
+</LoadBalancers></DescribeLoadBalancersResult></DescribeLoadBalancersResponse>`)
+		case "DescribeListeners":
+			assert.Equal(t, "arn:lb/internal-api", r.Form.Get("LoadBalancerArn"))
+			fmt.Fprint(w, `<DescribeListenersResponse><DescribeListenersResult><Listeners>
+<member><Port>8443</Port><Protocol>TLS</Protocol><Certificates><member><CertificateArn>arn:cert/a</CertificateArn></member><member><CertificateArn>arn:cert/b</CertificateArn></member></Certificates></member>
+<member><Port>80</Port><Protocol>TCP</Protocol></member>
+</Listeners></DescribeListenersResult></DescribeListenersResponse>`)
+		default:
+			t.Errorf("unexpected AWS call: %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func TestFetchTargetsFromAWSListeners(t *testing.T) {
+	useFakeAWS(t, fakeListeners(t))
+
+	targets, failures, err := FetchTargetsFromAWSListeners(nil, AWSAccounts{})
+
+	assert.NoError(t, err)
+	assert.Empty(t, failures)
+	assert.Len(t, targets, 3, "plain TCP listeners and distributions without aliases are skipped")
+
+	lb := targets[0]
+	assert.Equal(t, "internal-api-1.us-east-1.elb.amazonaws.com", lb.Host)
+	assert.Equal(t, []int{8443}, lb.Ports)
+	assert.Equal(t, "us-east-1", lb.Labels["aws_region"])
+	assert.Equal(t, "internal", lb.Labels["elb_scheme"])
+	assert.Equal(t, "arn:cert/a,arn:cert/b", lb.Labels["elb_cert_arns"])
+
+	cdn := targets[1]
+	assert.Equal(t, "cdn.example.com", cdn.Host)
+	assert.Equal(t, "d111.cloudfront.net", cdn.Address)
+	assert.Equal(t, []int{443}, cdn.Ports)
+	assert.Equal(t, "arn:aws:acm:us-east-1:1:certificate/cdn", cdn.Labels["cloudfront_cert_arn"])
+	assert.Equal(t, "static.example.com", targets[2].Host)
+}
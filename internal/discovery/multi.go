@@ -0,0 +1,189 @@
+package discovery
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/andre/ssl-cert-test/internal/config"
+)
+
+// NamedProvider is one member of a MultiProvider; Name ends up in the
+// "provider" label of everything it discovers
+type NamedProvider struct {
+	Name     string
+	Provider TargetProvider
+}
+
+// MultiProvider fetches several providers concurrently and merges their targets
+type MultiProvider struct {
+	Providers []NamedProvider
+}
+
+// FetchTargets runs every provider at once. Hostnames are normalized and deduped,
+// keeping the names of all providers that found them. Provider port lists are
+// merged into the default list; targets with their own ports keep them. A
+// failing provider is logged and reported as an error row instead of aborting
+// the run.
+func (p *MultiProvider) FetchTargets() (config.Config, error) {
+	confs := make([]config.Config, len(p.Providers))
+	errs := make([]error, len(p.Providers))
+
+	var wg sync.WaitGroup
+	for i, np := range p.Providers {
+		wg.Add(1)
+		go func(i int, np NamedProvider) {
+			defer wg.Done()
+			confs[i], errs[i] = np.Provider.FetchTargets()
+		}(i, np)
+	}
+	wg.Wait()
+
+	var merged config.Config
+	index := make(map[string]int)
+
+	for i, np := range p.Providers {
+		if errs[i] != nil {
+			slog.Warn("discovery provider failed", "provider", np.Name, "error", errs[i])
+			merged.Results = append(merged.Results, discoveryError("discovery", "provider:"+np.Name, map[string]string{"provider": np.Name}, errs[i]))
+			continue
+		}
+
+		conf := confs[i]
+		merged.Cidr = append(merged.Cidr, conf.Cidr...)
+		if len(conf.Ports) > 0 {
+			merged.Ports = config.MergePorts(merged.Ports, conf.Ports)
+		}
+
+		for _, t := range conf.AllTargets() {
+			t.Host = config.NormalizeHost(t.Host)
+			if t.Host == "" {
+				continue
+			}
+
+			key := targetKey(t)
+			if j, ok := index[key]; ok {
+				mergeProvenance(&merged.Targets[j], t, np.Name)
+				continue
+			}
+
+			labels := make(map[string]string, len(t.Labels)+1)
+			for k, v := range t.Labels {
+				labels[k] = v
+			}
+			labels["provider"] = np.Name
+			t.Labels = labels
+
+			index[key] = len(merged.Targets)
+			merged.Targets = append(merged.Targets, t)
+		}
+
+		for _, r := range conf.Results {
+			labels := make(map[string]string, len(r.Labels)+1)
+			for k, v := range r.Labels {
+				labels[k] = v
+			}
+			labels["provider"] = np.Name
+			r.Labels = labels
+			merged.Results = append(merged.Results, r)
+		}
+	}
+
+	return merged, nil
+}
+
+// targetKey identifies targets that dial the same endpoint the same way;
+// their ports are merged
+func targetKey(t config.Target) string {
+	return fmt.Sprintf("%s|%s|%s|%s", t.Host, t.Address, t.SNI, t.Protocol)
+}
+
+// mergeProvenance records another provider for an existing target and adds any
+// labels and ports it did not have yet. Owner, threshold and pins are filled
+// in when missing; conflicting values keep the first (or the earlier alert),
+// are logged, and are listed in the "merge_conflict" label.
+func mergeProvenance(existing *config.Target, dup config.Target, provider string) {
+	if len(dup.Ports) > 0 {
+		existing.Ports = config.MergePorts(existing.Ports, dup.Ports)
+	}
+
+	providers := strings.Split(existing.Labels["provider"], ",")
+	if !slices.Contains(providers, provider) {
+		providers = append(providers, provider)
+		sort.Strings(providers)
+		existing.Labels["provider"] = strings.Join(providers, ",")
+	}
+
+	for k, v := range dup.Labels {
+		if _, ok := existing.Labels[k]; !ok {
+			existing.Labels[k] = v
+		}
+	}
+
+	var conflicts []string
+	mergeField := func(field string, have *string, other string) {
+		switch {
+		case other == "" || strings.EqualFold(*have, other):
+		case *have == "":
+			*have = other
+		default:
+			slog.Warn("providers disagree on a target", "host", existing.Host, "field", field, "kept", *have, "ignored", other, "provider", provider)
+			conflicts = append(conflicts, field)
+		}
+	}
+	mergeField("owner", &existing.Owner, dup.Owner)
+	mergeField("expected_issuer", &existing.ExpectedIssuer, dup.ExpectedIssuer)
+	mergeField("expected_fingerprint", &existing.ExpectedFingerprint, dup.ExpectedFingerprint)
+
+	if dup.AlertDays != 0 && dup.AlertDays != existing.AlertDays {
+		if existing.AlertDays != 0 {
+			slog.Warn("providers disagree on a target", "host", existing.Host, "field", "alert_days", "kept", max(existing.AlertDays, dup.AlertDays), "provider", provider)
+			conflicts = append(conflicts, "alert_days")
+		}
+		existing.AlertDays = max(existing.AlertDays, dup.AlertDays)
+	}
+
+	if len(conflicts) > 0 {
+		if prev := existing.Labels["merge_conflict"]; prev != "" {
+			conflicts = append(strings.Split(prev, ","), conflicts...)
+		}
+		slices.Sort(conflicts)
+		existing.Labels["merge_conflict"] = strings.Join(slices.Compact(conflicts), ",")
+	}
+}
+
+// newMultiProvider builds one provider per -type entry, or per providers file entry
+func newMultiProvider(cfg *config.AppConfig) (TargetProvider, error) {
+	var specs []config.ProviderSpec
+	if cfg.ProvidersFile != "" {
+		var err error
+		specs, err = config.LoadProviderSpecs(cfg.ProvidersFile)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		for _, t := range config.SplitList(cfg.ConfigType) {
+			specs = append(specs, config.ProviderSpec{Name: t, Type: t})
+		}
+	}
+
+	multi := &MultiProvider{}
+	for _, spec := range specs {
+		sub, err := cfg.WithOverrides(spec.Settings)
+		if err != nil {
+			return nil, fmt.Errorf("provider %s: %w", spec.Name, err)
+		}
+		sub.ConfigType = spec.Type
+		sub.ProvidersFile = ""
+
+		provider, err := GetProvider(sub)
+		if err != nil {
+			return nil, fmt.Errorf("provider %s: %w", spec.Name, err)
+		}
+		multi.Providers = append(multi.Providers, NamedProvider{Name: spec.Name, Provider: provider})
+	}
+	return multi, nil
+}
@@ -0,0 +1,152 @@
+package discovery
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andre/ssl-cert-test/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// staticProvider returns a fixed configuration or error
+type staticProvider struct {
+	conf config.Config
+	err  error
+}
+
+func (p *staticProvider) FetchTargets() (config.Config, error) {
+	return p.conf, p.err
+}
+
+func TestMultiProvider_FetchTargets(t *testing.T) {
+	multi := &MultiProvider{Providers: []NamedProvider{
+		{Name: "route53", Provider: &staticProvider{conf: config.Config{
+			Targets: []config.Target{
+				{Host: "WWW.example.com.", Labels: map[string]string{"route53_zone": "example.com"}},
+				{Host: "api.example.com"},
+			},
+		}}},
+		{Name: "cloudflare", Provider: &staticProvider{err: errors.New("invalid token")}},
+		{Name: "gitlab", Provider: &staticProvider{conf: config.Config{
+			Domains: []string{"www.example.com", "legacy.example.com"},
+			Results: []config.DomainValidity{{Domain: "aws:123", Error: "denied"}},
+		}}},
+		{Name: "mail", Provider: &staticProvider{conf: config.Config{
+			Ports:   []int{465, 993},
+			Domains: []string{"mail.example.com"},
+			Targets: []config.Target{{Host: "smtp.example.com", Ports: []int{587}, Protocol: "smtp"}},
+		}}},
+	}}
+
+	conf, err := multi.FetchTargets()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []int{465, 993}, conf.Ports, "provider ports join the default list")
+
+	var hosts []string
+	for _, tgt := range conf.Targets {
+		hosts = append(hosts, tgt.Host)
+	}
+	assert.Equal(t, []string{"www.example.com", "api.example.com", "legacy.example.com", "mail.example.com", "smtp.example.com"}, hosts)
+	assert.Empty(t, conf.Targets[1].Ports)
+	assert.Empty(t, conf.Targets[3].Ports, "targets without ports use the default list")
+	assert.Equal(t, []int{587}, conf.Targets[4].Ports, "targets keep their own ports")
+
+	www := conf.Targets[0]
+	assert.Equal(t, "gitlab,route53", www.Labels["provider"], "duplicates keep every provider")
+	assert.Equal(t, "example.com", www.Labels["route53_zone"])
+	assert.Equal(t, "gitlab", conf.Targets[2].Labels["provider"])
+
+	assert.Len(t, conf.Results, 2)
+	assert.Equal(t, "provider:cloudflare", conf.Results[0].Domain)
+	assert.Contains(t, conf.Results[0].Error, "invalid token")
+	assert.Equal(t, "gitlab", conf.Results[1].Labels["provider"])
+}
+
+func TestMultiProvider_MergesExpectations(t *testing.T) {
+	multi := &MultiProvider{Providers: []NamedProvider{
+		{Name: "gitlab", Provider: &staticProvider{conf: config.Config{Targets: []config.Target{
+			{Host: "pay.example.com", Owner: "team-pay", AlertDays: 14, ExpectedFingerprint: "aa"},
+			{Host: "shop.example.com"},
+		}}}},
+		{Name: "netbox", Provider: &staticProvider{conf: config.Config{Targets: []config.Target{
+			{Host: "pay.example.com", Owner: "team-infra", AlertDays: 30, ExpectedFingerprint: "AA", ExpectedIssuer: "Internal CA"},
+			{Host: "shop.example.com", Owner: "team-shop", ExpectedFingerprint: "bb"},
+		}}}},
+		{Name: "kubernetes", Provider: &staticProvider{conf: config.Config{Targets: []config.Target{
+			{Host: "shop.example.com", ExpectedFingerprint: "cc"},
+		}}}},
+	}}
+
+	conf, err := multi.FetchTargets()
+	assert.NoError(t, err)
+	assert.Len(t, conf.Targets, 2)
+
+	pay := conf.Targets[0]
+	assert.Equal(t, "team-pay", pay.Owner, "the first owner is kept")
+	assert.Equal(t, 30, pay.AlertDays, "the earlier alert wins")
+	assert.Equal(t, "aa", pay.ExpectedFingerprint, "pins differing only in case agree")
+	assert.Equal(t, "Internal CA", pay.ExpectedIssuer, "missing values are filled in")
+	assert.Equal(t, "alert_days,owner", pay.Labels["merge_conflict"])
+
+	shop := conf.Targets[1]
+	assert.Equal(t, "team-shop", shop.Owner)
+	assert.Equal(t, "bb", shop.ExpectedFingerprint)
+	assert.Equal(t, "expected_fingerprint", shop.Labels["merge_conflict"])
+	assert.Equal(t, "gitlab,kubernetes,netbox", shop.Labels["provider"])
+}
+
+func TestMultiProvider_DedupesAcrossPortDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.json")
+	os.WriteFile(path, []byte(`{"domains": ["www.example.com"], "targets": [{"host": "api.example.com", "ports": [443]}]}`), 0o644)
+
+	multi := &MultiProvider{Providers: []NamedProvider{
+		{Name: "config", Provider: &FileProvider{Path: path}},
+		{Name: "route53", Provider: &staticProvider{conf: config.Config{Targets: []config.Target{
+			{Host: "www.example.com."},
+			{Host: "api.example.com", Ports: []int{8443}},
+		}}}},
+	}}
+
+	conf, err := multi.FetchTargets()
+	assert.NoError(t, err)
+	assert.Len(t, conf.Targets, 2, "the same host is scanned once whatever ports its providers declare")
+
+	www := conf.Targets[0]
+	assert.Equal(t, "config,route53", www.Labels["provider"])
+	assert.Empty(t, www.Ports, "file defaults are not pinned to the file's targets")
+	assert.ElementsMatch(t, config.DefaultPorts, conf.Ports)
+
+	assert.Equal(t, []int{443, 5061, 5091, 8080}, www.PortsFor(config.MergePorts(conf.Ports, []int{8080})), "one set of handshakes, -ports included")
+
+	assert.Equal(t, []int{443, 8443}, conf.Targets[1].Ports, "explicit ports are merged")
+}
+
+func TestGetProvider_Combined(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"a.json": `{"domains": ["a.example.com", "shared.example.com"]}`,
+		"b.json": `{"domains": ["shared.example.com", "b.example.com"]}`,
+	} {
+		os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644)
+	}
+	providersFile := filepath.Join(dir, "providers.json")
+	os.WriteFile(providersFile, []byte(`[
+		{"name": "team-a", "type": "config", "settings": {"config": "`+filepath.Join(dir, "a.json")+`"}},
+		{"name": "team-b", "type": "config", "settings": {"config": "`+filepath.Join(dir, "b.json")+`"}}
+	]`), 0o644)
+
+	provider, err := GetProvider(&config.AppConfig{ProvidersFile: providersFile})
+	assert.NoError(t, err)
+
+	conf, err := provider.FetchTargets()
+	assert.NoError(t, err)
+	assert.Len(t, conf.Targets, 3)
+	assert.Equal(t, "team-a,team-b", conf.Targets[1].Labels["provider"])
+
+	_, err = GetProvider(&config.AppConfig{ConfigType: "config,bogus"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "provider bogus")
+}
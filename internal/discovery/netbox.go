@@ -0,0 +1,386 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andre/ssl-cert-test/internal/config"
+)
+
+// NetBoxOptions selects what is pulled from NetBox. Multiple values of one
+// filter match any of them, except tags which must all be present.
+type NetBoxOptions struct {
+	URL      string
+	Token    string
+	Tags     []string
+	Tenants  []string // Tenant slugs
+	Sites    []string // Site slugs
+	Statuses []string // IP address and prefix statuses; empty means active
+
+	// PrefixTag marks the prefixes to expand and scan; empty skips prefixes
+	PrefixTag string
+	// CIDR holds the run's expansion limits; prefixes beyond them are skipped
+	CIDR config.CIDROptions
+}
+
+type netboxRef struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+type netboxIPAddress struct {
+	ID             int        `json:"id"`
+	Address        string     `json:"address"`
+	DNSName        string     `json:"dns_name"`
+	Tenant         *netboxRef `json:"tenant"`
+	AssignedObject *struct {
+		Device         *netboxRef `json:"device"`
+		VirtualMachine *netboxRef `json:"virtual_machine"`
+	} `json:"assigned_object"`
+}
+
+type netboxService struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Protocol *struct {
+		Value string `json:"value"`
+	} `json:"protocol"`
+	Ports          []int             `json:"ports"`
+	IPAddresses    []netboxIPAddress `json:"ipaddresses"`
+	Device         *netboxRef        `json:"device"`
+	VirtualMachine *netboxRef        `json:"virtual_machine"`
+
+	// NetBox 4.3+ replaced device/virtual_machine with a generic parent
+	ParentObjectType string     `json:"parent_object_type"`
+	Parent           *netboxRef `json:"parent"`
+}
+
+type netboxPrefix struct {
+	ID     int    `json:"id"`
+	Prefix string `json:"prefix"`
+}
+
+// netboxHost is a device or virtual machine, which is where sites live
+type netboxHost struct {
+	ID     int        `json:"id"`
+	Name   string     `json:"name"`
+	Site   *netboxRef `json:"site"`
+	Tenant *netboxRef `json:"tenant"`
+}
+
+type netboxPage[T any] struct {
+	Next    string `json:"next"`
+	Results []T    `json:"results"`
+}
+
+// netboxClient pages through the REST API with token auth
+type netboxClient struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// FetchTargetsFromNetBox pulls IP addresses with a DNS name, Service objects
+// with their ports, and prefixes tagged with PrefixTag. Targets carry the
+// NetBox tenant and site so alerts can be routed per team or location.
+// Prefixes are returned as plain CIDRs, so their addresses are unlabelled; a
+// prefix too large for the CIDR limits becomes an error row instead.
+func FetchTargetsFromNetBox(opts NetBoxOptions) (config.Config, error) {
+	var conf config.Config
+	if opts.URL == "" {
+		return conf, fmt.Errorf("netbox url is required")
+	}
+	nb := &netboxClient{
+		baseURL: strings.TrimSuffix(opts.URL, "/"),
+		token:   opts.Token,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+
+	statuses := opts.Statuses
+	if len(statuses) == 0 {
+		statuses = []string{"active"}
+	}
+
+	ipFilter := url.Values{"tag": opts.Tags, "tenant": opts.Tenants, "status": statuses, "dns_name__empty": {"false"}}
+	ips, err := netboxList[netboxIPAddress](nb, "/api/ipam/ip-addresses/", ipFilter)
+	if err != nil {
+		return conf, err
+	}
+	ipsByID := make(map[int]netboxIPAddress, len(ips))
+	for _, ip := range ips {
+		ipsByID[ip.ID] = ip
+	}
+
+	services, err := netboxList[netboxService](nb, "/api/ipam/services/", url.Values{"tag": opts.Tags})
+	if err != nil {
+		return conf, err
+	}
+
+	// Sites (and service tenants) are only known through the device or VM
+	devices, vms, err := nb.hosts(ips, services)
+	if err != nil {
+		return conf, err
+	}
+	hostOf := func(device, vm *netboxRef) *netboxHost {
+		if device != nil {
+			return devices[device.ID]
+		}
+		if vm != nil {
+			return vms[vm.ID]
+		}
+		return nil
+	}
+
+	var targets []config.Target
+	covered := make(map[string]bool) // Named IPs already scanned on their service ports
+
+	for _, svc := range services {
+		if svc.Protocol != nil && svc.Protocol.Value != "tcp" {
+			continue
+		}
+		device, vm := svc.Device, svc.VirtualMachine
+		switch svc.ParentObjectType {
+		case "dcim.device":
+			device = svc.Parent
+		case "virtualization.virtualmachine":
+			vm = svc.Parent
+		}
+		host := hostOf(device, vm)
+		if !netboxMatches(opts, nil, host) {
+			continue
+		}
+
+		for _, nested := range svc.IPAddresses {
+			addr := netboxIP(nested.Address)
+			if addr == "" {
+				continue
+			}
+			// Nested addresses are brief objects without dns_name or tenant;
+			// the full record is in the list fetched above unless it was filtered out
+			ip, ok := ipsByID[nested.ID]
+			if !ok {
+				ip = nested
+			}
+			if ip.DNSName != "" {
+				covered[addr] = true
+			}
+
+			t := netboxTarget(ip, addr, host)
+			t.Ports = svc.Ports
+			t.Labels["netbox_service"] = svc.Name
+			targets = append(targets, t)
+		}
+		if len(svc.IPAddresses) == 0 {
+			slog.Debug("netbox service has no ip addresses, skipping", "service", svc.Name, "id", svc.ID)
+		}
+	}
+
+	for _, ip := range ips {
+		addr := netboxIP(ip.Address)
+		if addr == "" || ip.DNSName == "" || covered[addr] {
+			continue
+		}
+		var host *netboxHost
+		if ip.AssignedObject != nil {
+			host = hostOf(ip.AssignedObject.Device, ip.AssignedObject.VirtualMachine)
+		}
+		if !netboxMatches(opts, ip.Tenant, host) {
+			continue
+		}
+		targets = append(targets, netboxTarget(ip, addr, host))
+	}
+
+	conf.Targets = targets
+	if opts.PrefixTag != "" {
+		prefixFilter := url.Values{
+			"tag":    append([]string{opts.PrefixTag}, opts.Tags...),
+			"tenant": opts.Tenants,
+			"site":   opts.Sites,
+			"status": statuses,
+		}
+		list, err := netboxList[netboxPrefix](nb, "/api/ipam/prefixes/", prefixFilter)
+		if err != nil {
+			return conf, err
+		}
+		for _, p := range list {
+			if _, _, err := config.ParseCIDR(p.Prefix, opts.CIDR); err != nil {
+				labels := map[string]string{"netbox_prefix": strconv.Itoa(p.ID)}
+				conf.Results = append(conf.Results, discoveryError("netbox", p.Prefix, labels, err))
+				continue
+			}
+			conf.Cidr = append(conf.Cidr, p.Prefix)
+		}
+	}
+
+	return conf, nil
+}
+
+// netboxTarget builds the target of one IP, named by its DNS name when set
+func netboxTarget(ip netboxIPAddress, addr string, host *netboxHost) config.Target {
+	t := config.Target{Host: addr, Labels: map[string]string{}}
+	if name := config.NormalizeHost(ip.DNSName); name != "" {
+		t.Host = name
+		t.Address = addr
+	}
+
+	tenant := ip.Tenant
+	if host != nil {
+		t.Labels["netbox_device"] = host.Name
+		if host.Site != nil {
+			t.Labels["netbox_site"] = host.Site.Slug
+		}
+		if tenant == nil {
+			tenant = host.Tenant
+		}
+	}
+	if tenant != nil {
+		t.Labels["netbox_tenant"] = tenant.Slug
+	}
+	return t
+}
+
+// netboxMatches applies the tenant and site filters that the API cannot apply
+// itself. The object's own tenant wins over its device's.
+func netboxMatches(opts NetBoxOptions, tenant *netboxRef, host *netboxHost) bool {
+	if tenant == nil && host != nil {
+		tenant = host.Tenant
+	}
+	if len(opts.Tenants) > 0 && (tenant == nil || !slices.Contains(opts.Tenants, tenant.Slug)) {
+		return false
+	}
+	if len(opts.Sites) > 0 && (host == nil || host.Site == nil || !slices.Contains(opts.Sites, host.Site.Slug)) {
+		return false
+	}
+	return true
+}
+
+// hosts fetches the devices and VMs referenced by the IPs and services, in chunks
+func (nb *netboxClient) hosts(ips []netboxIPAddress, services []netboxService) (map[int]*netboxHost, map[int]*netboxHost, error) {
+	deviceIDs := make(map[int]bool)
+	vmIDs := make(map[int]bool)
+	add := func(device, vm *netboxRef) {
+		if device != nil {
+			deviceIDs[device.ID] = true
+		}
+		if vm != nil {
+			vmIDs[vm.ID] = true
+		}
+	}
+	for _, ip := range ips {
+		if ip.AssignedObject != nil {
+			add(ip.AssignedObject.Device, ip.AssignedObject.VirtualMachine)
+		}
+	}
+	for _, svc := range services {
+		add(svc.Device, svc.VirtualMachine)
+		switch svc.ParentObjectType {
+		case "dcim.device":
+			add(svc.Parent, nil)
+		case "virtualization.virtualmachine":
+			add(nil, svc.Parent)
+		}
+	}
+
+	devices, err := nb.hostsByID("/api/dcim/devices/", deviceIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	vms, err := nb.hostsByID("/api/virtualization/virtual-machines/", vmIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return devices, vms, nil
+}
+
+func (nb *netboxClient) hostsByID(path string, ids map[int]bool) (map[int]*netboxHost, error) {
+	hosts := make(map[int]*netboxHost)
+
+	var chunk []string
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		list, err := netboxList[netboxHost](nb, path, url.Values{"id": chunk})
+		if err != nil {
+			return err
+		}
+		for i := range list {
+			hosts[list[i].ID] = &list[i]
+		}
+		chunk = nil
+		return nil
+	}
+
+	for id := range ids {
+		chunk = append(chunk, strconv.Itoa(id))
+		if len(chunk) == 100 {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return hosts, nil
+}
+
+// netboxList follows the "next" links of a list endpoint and returns every result
+func netboxList[T any](nb *netboxClient, path string, filter url.Values) ([]T, error) {
+	query := url.Values{"limit": {"1000"}}
+	for k, v := range filter {
+		if len(v) > 0 {
+			query[k] = v
+		}
+	}
+
+	var all []T
+	next := nb.baseURL + path + "?" + query.Encode()
+	for next != "" {
+		req, err := http.NewRequest("GET", next, nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid netbox url: %w", err)
+		}
+		req.Header.Set("Accept", "application/json")
+		if nb.token != "" {
+			req.Header.Set("Authorization", "Token "+nb.token)
+		}
+
+		resp, err := nb.http.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query netbox: %w", err)
+		}
+		var page netboxPage[T]
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("netbox %s returned status %d", path, resp.StatusCode)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse netbox response: %w", err)
+		}
+
+		all = append(all, page.Results...)
+		next = page.Next
+	}
+	return all, nil
+}
+
+// netboxIP strips the prefix length NetBox stores with every address
+func netboxIP(address string) string {
+	ip, _, err := net.ParseCIDR(address)
+	if err != nil {
+		return ""
+	}
+	return ip.String()
+}
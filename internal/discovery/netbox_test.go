@@ -0,0 +1,144 @@
+package discovery
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/andre/ssl-cert-test/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeNetBox replays recorded (trimmed) NetBox 4.x API responses
+func fakeNetBox(t *testing.T) *httptest.Server {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token nb-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		q := r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/api/ipam/ip-addresses/":
+			assert.Equal(t, []string{"active"}, q["status"])
+			assert.Equal(t, "false", q.Get("dns_name__empty"))
+			if q.Get("offset") == "" {
+				// NetBox keeps the filters in the next link
+				next := r.URL.Query()
+				next.Set("offset", "2")
+				fmt.Fprintf(w, `{"count": 4, "next": "%s/api/ipam/ip-addresses/?%s", "previous": null, "results": [
+					{"id": 1, "address": "10.0.0.10/24", "dns_name": "www.example.com", "status": {"value": "active"},
+					 "tenant": {"id": 3, "name": "Web", "slug": "web"},
+					 "assigned_object_type": "dcim.interface", "assigned_object": {"id": 11, "name": "eth0", "device": {"id": 1, "name": "web01"}}},
+					{"id": 2, "address": "10.0.0.11/24", "dns_name": "api.example.com", "status": {"value": "active"}, "tenant": null,
+					 "assigned_object_type": "virtualization.vminterface", "assigned_object": {"id": 51, "name": "eth0", "virtual_machine": {"id": 5, "name": "api-vm"}}}
+				]}`, ts.URL, next.Encode())
+				return
+			}
+			fmt.Fprint(w, `{"count": 4, "next": null, "previous": null, "results": [
+				{"id": 3, "address": "10.0.0.12/24", "dns_name": "", "status": {"value": "active"}, "tenant": null, "assigned_object": null},
+				{"id": 4, "address": "10.0.1.5/24", "dns_name": "Lab.Example.com", "status": {"value": "active"}, "tenant": null,
+				 "assigned_object_type": "dcim.interface", "assigned_object": {"id": 21, "name": "eth0", "device": {"id": 2, "name": "lab01"}}}
+			]}`)
+		case "/api/ipam/services/":
+			// Nested addresses are brief objects: no dns_name, tenant or tags
+			fmt.Fprint(w, `{"count": 4, "next": null, "previous": null, "results": [
+				{"id": 1, "name": "https-api", "protocol": {"value": "tcp"}, "ports": [443, 8443],
+				 "parent_object_type": "virtualization.virtualmachine", "parent": {"id": 5, "name": "api-vm"},
+				 "ipaddresses": [{"id": 2, "url": "/api/ipam/ip-addresses/2/", "display": "10.0.0.11/24", "family": {"value": 4, "label": "IPv4"}, "address": "10.0.0.11/24", "description": ""}]},
+				{"id": 2, "name": "dns", "protocol": {"value": "udp"}, "ports": [53], "device": {"id": 1, "name": "web01"},
+				 "ipaddresses": [{"id": 1, "url": "/api/ipam/ip-addresses/1/", "display": "10.0.0.10/24", "family": {"value": 4, "label": "IPv4"}, "address": "10.0.0.10/24", "description": ""}]},
+				{"id": 3, "name": "ssh", "protocol": {"value": "tcp"}, "ports": [22], "device": {"id": 2, "name": "lab01"}, "ipaddresses": []},
+				{"id": 4, "name": "legacy-https", "protocol": {"value": "tcp"}, "ports": [443], "device": {"id": 2, "name": "lab01"},
+				 "ipaddresses": [{"id": 7, "url": "/api/ipam/ip-addresses/7/", "display": "10.0.1.9/24", "family": {"value": 4, "label": "IPv4"}, "address": "10.0.1.9/24", "description": ""}]}
+			]}`)
+		case "/api/dcim/devices/":
+			ids := q["id"]
+			sort.Strings(ids)
+			assert.Equal(t, []string{"1", "2"}, ids, "only referenced devices are fetched")
+			fmt.Fprint(w, `{"count": 2, "next": null, "previous": null, "results": [
+				{"id": 1, "name": "web01", "site": {"id": 1, "name": "DC1", "slug": "dc1"}, "tenant": {"id": 3, "name": "Web", "slug": "web"}},
+				{"id": 2, "name": "lab01", "site": {"id": 2, "name": "Lab", "slug": "lab"}, "tenant": null}
+			]}`)
+		case "/api/virtualization/virtual-machines/":
+			fmt.Fprint(w, `{"count": 1, "next": null, "previous": null, "results": [
+				{"id": 5, "name": "api-vm", "site": {"id": 1, "name": "DC1", "slug": "dc1"}, "tenant": {"id": 4, "name": "API", "slug": "api-team"}}
+			]}`)
+		case "/api/ipam/prefixes/":
+			assert.Equal(t, "ssl-scan", q.Get("tag"))
+			fmt.Fprint(w, `{"count": 2, "next": null, "previous": null, "results": [{"id": 9, "prefix": "10.0.2.0/28"}, {"id": 10, "prefix": "10.8.0.0/16"}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return ts
+}
+
+func TestFetchTargetsFromNetBox(t *testing.T) {
+	ts := fakeNetBox(t)
+	defer ts.Close()
+
+	conf, err := FetchTargetsFromNetBox(NetBoxOptions{
+		URL:       ts.URL + "/",
+		Token:     "nb-token",
+		PrefixTag: "ssl-scan",
+		CIDR:      config.CIDROptions{MaxSize: 1024},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.2.0/28"}, conf.Cidr)
+	assert.Len(t, conf.Results, 1, "a prefix over the size limit is skipped, not fatal")
+	assert.Equal(t, "10.8.0.0/16", conf.Results[0].Domain)
+	assert.Contains(t, conf.Results[0].Error, "more than the limit of 1024")
+
+	targets := conf.Targets
+	assert.Len(t, targets, 4)
+
+	api := targets[0]
+	assert.Equal(t, "api.example.com", api.Host)
+	assert.Equal(t, "10.0.0.11", api.Address)
+	assert.Equal(t, []int{443, 8443}, api.Ports, "service ports become per-target ports")
+	assert.Equal(t, map[string]string{
+		"netbox_service": "https-api",
+		"netbox_device":  "api-vm",
+		"netbox_site":    "dc1",
+		"netbox_tenant":  "api-team",
+	}, api.Labels, "the nested address is resolved to its full record for the name and labels")
+
+	legacy := targets[1]
+	assert.Equal(t, "10.0.1.9", legacy.Host, "addresses without a DNS name are scanned by IP")
+	assert.Equal(t, []int{443}, legacy.Ports)
+	assert.Equal(t, "lab", legacy.Labels["netbox_site"])
+
+	www := targets[2]
+	assert.Equal(t, "www.example.com", www.Host)
+	assert.Empty(t, www.Ports, "udp services are ignored, so the default ports apply")
+	assert.Equal(t, "web", www.Labels["netbox_tenant"])
+
+	lab := targets[3]
+	assert.Equal(t, "lab.example.com", lab.Host)
+	assert.Equal(t, "lab", lab.Labels["netbox_site"])
+	assert.NotContains(t, lab.Labels, "netbox_tenant")
+}
+
+func TestFetchTargetsFromNetBox_Filters(t *testing.T) {
+	ts := fakeNetBox(t)
+	defer ts.Close()
+
+	conf, err := FetchTargetsFromNetBox(NetBoxOptions{URL: ts.URL, Token: "nb-token", Sites: []string{"dc1"}, Tenants: []string{"web"}})
+
+	assert.NoError(t, err)
+	assert.Empty(t, conf.Cidr, "prefixes need a prefix tag")
+	var hosts []string
+	for _, target := range conf.Targets {
+		hosts = append(hosts, target.Host)
+	}
+	assert.Equal(t, []string{"www.example.com"}, hosts)
+
+	_, err = FetchTargetsFromNetBox(NetBoxOptions{URL: ts.URL, Token: "wrong"})
+	assert.ErrorContains(t, err, "status 403")
+}
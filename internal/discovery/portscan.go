@@ -0,0 +1,241 @@
+package discovery
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/andre/ssl-cert-test/internal/config"
+)
+
+// PortScanOptions selects the scan file to import. Only ports that speak TLS
+// are imported unless AllPorts is set.
+type PortScanOptions struct {
+	Path     string
+	AllPorts bool
+}
+
+// tlsPorts are the well-known ports of TLS services, with the STARTTLS
+// protocol to speak first, used when the scanner did not name the service
+var tlsPorts = map[int]string{
+	443: "", 465: "", 636: "", 853: "", 990: "", 992: "", 993: "", 995: "", 5061: "", 5091: "", 5986: "", 8443: "", 9443: "",
+	21: "ftp", 25: "smtp", 110: "pop3", 143: "imap", 587: "smtp",
+}
+
+// tlsServices are scanner service names of ports wrapped in TLS
+var tlsServices = map[string]bool{
+	"https": true, "https-alt": true, "imaps": true, "pop3s": true, "ldaps": true, "smtps": true,
+	"submissions": true, "ftps": true, "domain-s": true, "ircs-u": true, "sip-tls": true, "ssl": true, "x509": true,
+}
+
+// FetchTargetsFromPortScan imports the open TCP ports of an Nmap XML (-oX) or
+// masscan JSON (-oJ) file, one target per host:port. Nmap service names become
+// STARTTLS hints and reverse DNS names are used as SNI. Plain services (ssh,
+// http, databases, ...) are dropped unless opts.AllPorts is set.
+func FetchTargetsFromPortScan(opts PortScanOptions) ([]config.Target, error) {
+	if opts.Path == "" {
+		return nil, fmt.Errorf("port scan file path is required")
+	}
+
+	data, err := os.ReadFile(opts.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read port scan file: %w", err)
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("<")) {
+		return parseNmapXML(trimmed, opts.AllPorts)
+	}
+	return parseMasscanJSON(trimmed, opts.AllPorts)
+}
+
+type nmapRun struct {
+	Hosts []struct {
+		Status struct {
+			State string `xml:"state,attr"`
+		} `xml:"status"`
+		Addresses []struct {
+			Addr     string `xml:"addr,attr"`
+			AddrType string `xml:"addrtype,attr"`
+		} `xml:"address"`
+		Hostnames []struct {
+			Name string `xml:"name,attr"`
+			Type string `xml:"type,attr"`
+		} `xml:"hostnames>hostname"`
+		Ports []struct {
+			Protocol string `xml:"protocol,attr"`
+			PortID   int    `xml:"portid,attr"`
+			State    struct {
+				State string `xml:"state,attr"`
+			} `xml:"state"`
+			Service struct {
+				Name   string `xml:"name,attr"`
+				Tunnel string `xml:"tunnel,attr"`
+			} `xml:"service"`
+		} `xml:"ports>port"`
+	} `xml:"host"`
+}
+
+func parseNmapXML(data []byte, allPorts bool) ([]config.Target, error) {
+	var run nmapRun
+	if err := xml.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("failed to parse nmap xml: %w", err)
+	}
+
+	var targets []config.Target
+	for _, h := range run.Hosts {
+		if h.Status.State != "" && h.Status.State != "up" {
+			continue
+		}
+
+		var ip string
+		for _, a := range h.Addresses {
+			if a.AddrType == "ipv4" || a.AddrType == "ipv6" {
+				ip = a.Addr
+				break
+			}
+		}
+		if ip == "" {
+			continue
+		}
+
+		// Prefer the PTR name, then a user-supplied one, as SNI
+		var hostname string
+		for _, hn := range h.Hostnames {
+			if hn.Type == "PTR" || hostname == "" {
+				hostname = hn.Name
+			}
+		}
+
+		for _, p := range h.Ports {
+			if p.Protocol != "tcp" || p.State.State != "open" {
+				continue
+			}
+			if _, ok := tlsProtocol(p.PortID, p.Service.Name, p.Service.Tunnel); !ok && !allPorts {
+				continue
+			}
+			targets = append(targets, portScanTarget("nmap", ip, hostname, p.PortID, p.Service.Name, p.Service.Tunnel))
+		}
+	}
+	return targets, nil
+}
+
+type masscanHost struct {
+	IP    string `json:"ip"`
+	Ports []struct {
+		Port    int    `json:"port"`
+		Proto   string `json:"proto"`
+		Status  string `json:"status"`
+		Service struct {
+			Name string `json:"name"`
+		} `json:"service"`
+	} `json:"ports"`
+}
+
+// parseMasscanJSON accepts a JSON array as well as masscan's line-per-record
+// output, which older versions emit with trailing commas
+func parseMasscanJSON(data []byte, allPorts bool) ([]config.Target, error) {
+	var hosts []masscanHost
+	if err := json.Unmarshal(data, &hosts); err != nil {
+		hosts = nil
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := strings.TrimSuffix(strings.TrimSpace(scanner.Text()), ",")
+			if line == "" || line == "[" || line == "]" {
+				continue
+			}
+			var h masscanHost
+			if err := json.Unmarshal([]byte(line), &h); err != nil {
+				return nil, fmt.Errorf("failed to parse masscan json: %w", err)
+			}
+			hosts = append(hosts, h)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read masscan json: %w", err)
+		}
+	}
+
+	// Banner records repeat the port with a service but no status
+	services := make(map[string]string)
+	for _, h := range hosts {
+		for _, p := range h.Ports {
+			if p.Service.Name != "" {
+				services[fmt.Sprintf("%s:%d", h.IP, p.Port)] = p.Service.Name
+			}
+		}
+	}
+
+	var targets []config.Target
+	for _, h := range hosts {
+		for _, p := range h.Ports {
+			if h.IP == "" || p.Proto != "tcp" || p.Status != "open" {
+				continue
+			}
+			service := services[fmt.Sprintf("%s:%d", h.IP, p.Port)]
+			if _, ok := tlsProtocol(p.Port, service, ""); !ok && !allPorts {
+				continue
+			}
+			targets = append(targets, portScanTarget("masscan", h.IP, "", p.Port, service, ""))
+		}
+	}
+	return targets, nil
+}
+
+func portScanTarget(source, ip, hostname string, port int, service, tunnel string) config.Target {
+	protocol, _ := tlsProtocol(port, service, tunnel)
+	target := config.Target{
+		Host:     ip,
+		Ports:    []int{port},
+		Protocol: protocol,
+		Labels:   map[string]string{"portscan_source": source},
+	}
+	if hostname != "" {
+		target.Host = config.NormalizeHost(hostname)
+		target.Address = ip
+	}
+	if service != "" {
+		target.Labels["portscan_service"] = service
+	}
+	return target
+}
+
+// tlsProtocol reports whether a port speaks TLS and the STARTTLS protocol to
+// use first. The scanner's service name decides when there is one; unnamed
+// ports fall back to the well-known port list.
+func tlsProtocol(port int, service, tunnel string) (string, bool) {
+	if tunnel == "ssl" || tlsServices[strings.ToLower(service)] {
+		return "", true
+	}
+	if hint := startTLSHint(service, tunnel); hint != "" {
+		return hint, true
+	}
+	if service != "" {
+		return "", false
+	}
+	protocol, ok := tlsPorts[port]
+	return protocol, ok
+}
+
+// startTLSHint maps a scanner service name to the STARTTLS protocol to speak first.
+// Ports already wrapped in TLS (tunnel="ssl", e.g. imaps) are dialled directly.
+func startTLSHint(service, tunnel string) string {
+	if tunnel == "ssl" {
+		return ""
+	}
+	switch strings.ToLower(service) {
+	case "smtp", "submission":
+		return "smtp"
+	case "imap":
+		return "imap"
+	case "pop3":
+		return "pop3"
+	case "ftp":
+		return "ftp"
+	}
+	return ""
+}
@@ -0,0 +1,118 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeScanFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFetchTargetsFromPortScan_Nmap(t *testing.T) {
+	path := writeScanFile(t, "scan.xml", `<?xml version="1.0"?>
+<nmaprun scanner="nmap">
+  <host>
+    <status state="up"/>
+    <address addr="10.0.0.25" addrtype="ipv4"/>
+    <address addr="00:11:22:33:44:55" addrtype="mac"/>
+    <hostnames><hostname name="Mail.Corp.Example." type="PTR"/></hostnames>
+    <ports>
+      <port protocol="tcp" portid="22"><state state="open"/><service name="ssh"/></port>
+      <port protocol="tcp" portid="25"><state state="open"/><service name="smtp"/></port>
+      <port protocol="tcp" portid="53"><state state="open"/><service name="domain"/></port>
+      <port protocol="tcp" portid="80"><state state="open"/><service name="http"/></port>
+      <port protocol="tcp" portid="993"><state state="open"/><service name="imap" tunnel="ssl"/></port>
+      <port protocol="tcp" portid="8443"><state state="filtered"/><service name="https-alt"/></port>
+      <port protocol="udp" portid="443"><state state="open"/></port>
+    </ports>
+  </host>
+  <host>
+    <status state="down"/>
+    <address addr="10.0.0.26" addrtype="ipv4"/>
+  </host>
+  <host>
+    <status state="up"/>
+    <address addr="10.0.0.27" addrtype="ipv4"/>
+    <ports><port protocol="tcp" portid="443"><state state="open"/><service name="https" tunnel="ssl"/></port></ports>
+  </host>
+  <host>
+    <status state="up"/>
+    <address addr="10.0.0.28" addrtype="ipv4"/>
+    <ports>
+      <port protocol="tcp" portid="5061"><state state="open"/><service name="sip-tls"/></port>
+      <port protocol="tcp" portid="5091"><state state="open"/></port>
+    </ports>
+  </host>
+</nmaprun>`)
+
+	targets, err := FetchTargetsFromPortScan(PortScanOptions{Path: path})
+
+	assert.NoError(t, err)
+	assert.Len(t, targets, 5, "ssh, dns and plain http are dropped")
+
+	smtp := targets[0]
+	assert.Equal(t, "mail.corp.example", smtp.Host, "reverse DNS name is used as SNI")
+	assert.Equal(t, "10.0.0.25", smtp.Address)
+	assert.Equal(t, []int{25}, smtp.Ports)
+	assert.Equal(t, "smtp", smtp.Protocol)
+	assert.Equal(t, "nmap", smtp.Labels["portscan_source"])
+
+	imaps := targets[1]
+	assert.Equal(t, []int{993}, imaps.Ports)
+	assert.Empty(t, imaps.Protocol, "ports already wrapped in TLS are dialled directly")
+
+	noPTR := targets[2]
+	assert.Equal(t, "10.0.0.27", noPTR.Host)
+	assert.Empty(t, noPTR.Address)
+
+	assert.Equal(t, []int{5061}, targets[3].Ports, "SIP over TLS is one of the default ports")
+	assert.Equal(t, []int{5091}, targets[4].Ports)
+
+	all, err := FetchTargetsFromPortScan(PortScanOptions{Path: path, AllPorts: true})
+	assert.NoError(t, err)
+	assert.Len(t, all, 8, "every open TCP port is imported on request")
+	assert.Equal(t, []int{22}, all[0].Ports)
+	assert.Empty(t, all[0].Protocol)
+}
+
+func TestFetchTargetsFromPortScan_Masscan(t *testing.T) {
+	// masscan -oJ output, trailing commas included
+	path := writeScanFile(t, "scan.json", `[
+{   "ip": "192.0.2.5",   "timestamp": "1700000000", "ports": [ {"port": 443, "proto": "tcp", "status": "open", "reason": "syn-ack", "ttl": 64} ] },
+{   "ip": "192.0.2.5",   "timestamp": "1700000001", "ports": [ {"port": 443, "proto": "tcp", "service": {"name": "ssl", "banner": "TLS/1.2"} } ] },
+{   "ip": "192.0.2.6",   "timestamp": "1700000002", "ports": [ {"port": 587, "proto": "tcp", "status": "open", "reason": "syn-ack", "ttl": 64} ] },
+{   "ip": "192.0.2.6",   "timestamp": "1700000003", "ports": [ {"port": 22, "proto": "tcp", "status": "open", "reason": "syn-ack", "ttl": 64} ] },
+{   "ip": "192.0.2.7",   "timestamp": "1700000004", "ports": [ {"port": 8080, "proto": "tcp", "status": "open", "reason": "syn-ack", "ttl": 64} ] },
+{   "ip": "192.0.2.7",   "timestamp": "1700000005", "ports": [ {"port": 8080, "proto": "tcp", "service": {"name": "ssl", "banner": "TLS/1.3"} } ] },
+{   "ip": "192.0.2.7",   "timestamp": "1700000006", "ports": [ {"port": 443, "proto": "tcp", "status": "open", "reason": "syn-ack", "ttl": 64} ] },
+{   "ip": "192.0.2.7",   "timestamp": "1700000007", "ports": [ {"port": 443, "proto": "tcp", "service": {"name": "http", "banner": "HTTP/1.1 200 OK"} } ] },
+]`)
+
+	targets, err := FetchTargetsFromPortScan(PortScanOptions{Path: path})
+
+	assert.NoError(t, err)
+	assert.Len(t, targets, 3, "banner records without a status are not duplicated")
+	assert.Equal(t, "192.0.2.5", targets[0].Host)
+	assert.Equal(t, []int{443}, targets[0].Ports)
+	assert.Equal(t, "masscan", targets[0].Labels["portscan_source"])
+	assert.Equal(t, []int{587}, targets[1].Ports, "unnamed ports fall back to the well-known TLS ports")
+	assert.Equal(t, "smtp", targets[1].Protocol)
+	assert.Equal(t, []int{8080}, targets[2].Ports, "a TLS banner marks any port")
+}
+
+func TestFetchTargetsFromPortScan_Invalid(t *testing.T) {
+	_, err := FetchTargetsFromPortScan(PortScanOptions{Path: writeScanFile(t, "scan.json", "not a scan")})
+	assert.Error(t, err)
+
+	_, err = FetchTargetsFromPortScan(PortScanOptions{})
+	assert.Error(t, err)
+}
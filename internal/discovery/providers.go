@@ -2,6 +2,9 @@ package discovery
 
 import (
 	"fmt"
+	"strings"
+	"time"
+
 	"github.com/andre/ssl-cert-test/internal/config"
 )
 
@@ -24,75 +27,433 @@ func (p *FileProvider) FetchTargets() (config.Config, error) {
 // 2. Route53 Provider
 type Route53Provider struct {
 	HostedZoneID string
+	AllZones     bool // Walk every hosted zone in the account instead of HostedZoneID
+	ZoneFilter   Route53ZoneFilter
+	Accounts     AWSAccounts // When a role is set, walk every zone of each account
+	Expand       bool        // Scan each weighted/latency/failover value with the record name as SNI
 }
 
 func (p *Route53Provider) FetchTargets() (config.Config, error) {
-	domains, err := FetchDomainsFromRoute53(p.HostedZoneID)
-	// Return a Config struct with just the domains populated
-	return config.Config{Domains: domains}, err
+	if p.Accounts.RoleName != "" {
+		targets, failures, err := FetchTargetsFromRoute53Accounts(p.Accounts, p.ZoneFilter, p.Expand)
+		return config.Config{Targets: targets, Results: failures}, err
+	}
+
+	if p.AllZones {
+		targets, err := FetchTargetsFromRoute53Zones(p.ZoneFilter, p.Expand)
+		return config.Config{Targets: targets}, err
+	}
+
+	targets, err := FetchTargetsFromRoute53(p.HostedZoneID, p.Expand)
+	return config.Config{Targets: targets}, err
+}
+
+// 2b. AWS certificate inventory (ACM and IAM server certificates)
+type AWSCertsProvider struct {
+	Options AWSCertOptions
+}
+
+func (p *AWSCertsProvider) FetchTargets() (config.Config, error) {
+	results, err := FetchAWSCertificates(p.Options, time.Now())
+	return config.Config{Results: results}, err
+}
+
+// 2c. AWS load balancer listeners and CloudFront alternate domain names
+type AWSListenersProvider struct {
+	Regions  []string
+	Accounts AWSAccounts
+}
+
+func (p *AWSListenersProvider) FetchTargets() (config.Config, error) {
+	targets, failures, err := FetchTargetsFromAWSListeners(p.Regions, p.Accounts)
+	return config.Config{Targets: targets, Results: failures}, err
 }
 
 // 3. Cloudflare Provider
 type CloudflareProvider struct {
-	Token  string
-	ZoneID string
+	Token   string
+	Options CloudflareOptions
 }
 
 func (p *CloudflareProvider) FetchTargets() (config.Config, error) {
-	domains, err := FetchDomainsFromCloudflare(p.Token, p.ZoneID)
-	return config.Config{Domains: domains}, err
+	targets, err := FetchTargetsFromCloudflare(p.Token, p.Options)
+	return config.Config{Targets: targets}, err
+}
+
+// 3b. Cloudflare certificate inventory (edge certificate packs and Origin CA)
+type CloudflareCertsProvider struct {
+	Token   string
+	Options CloudflareOptions
+}
+
+func (p *CloudflareCertsProvider) FetchTargets() (config.Config, error) {
+	results, err := FetchCloudflareCertificates(p.Token, p.Options, time.Now())
+	return config.Config{Results: results}, err
 }
 
 // 4. Azure Provider
 type AzureProvider struct {
 	SubID, ResGroup, Zone, ClientID, ClientSecret, TenantID string
+
+	// Walk every public and private zone of these subscriptions instead of Zone
+	Subscriptions []string
 }
 
 func (p *AzureProvider) FetchTargets() (config.Config, error) {
+	if len(p.Subscriptions) > 0 {
+		targets, failures, err := FetchTargetsFromAzureSubscriptions(p.Subscriptions, p.ClientID, p.ClientSecret, p.TenantID)
+		return config.Config{Targets: targets, Results: failures}, err
+	}
+
 	domains, err := FetchDomainsFromAzure(p.SubID, p.ResGroup, p.Zone, p.ClientID, p.ClientSecret, p.TenantID)
 	return config.Config{Domains: domains}, err
 }
 
+// 4b. Google Cloud DNS Provider
+type GCPProvider struct {
+	Projects        []string
+	CredentialsFile string // Service account JSON; empty uses Application Default Credentials
+}
+
+func (p *GCPProvider) FetchTargets() (config.Config, error) {
+	targets, failures, err := FetchTargetsFromGCP(p.Projects, p.CredentialsFile)
+	return config.Config{Targets: targets, Results: failures}, err
+}
+
+// 4c. Kubernetes Provider (TLS secrets, Ingress and Gateway listeners)
+type KubernetesProvider struct {
+	Options KubernetesOptions
+}
+
+func (p *KubernetesProvider) FetchTargets() (config.Config, error) {
+	targets, results, err := FetchFromKubernetes(p.Options, time.Now())
+	return config.Config{Targets: targets, Results: results}, err
+}
+
+// 4d. BIND zone file Provider
+type ZoneFileProvider struct {
+	Path, Origin string
+}
+
+func (p *ZoneFileProvider) FetchTargets() (config.Config, error) {
+	targets, err := FetchTargetsFromZoneFile(p.Path, p.Origin)
+	return config.Config{Targets: targets}, err
+}
+
+// 4e. Zone transfer (AXFR) Provider
+type AXFRProvider struct {
+	Server, Zone string
+	Key          TSIGKey
+}
+
+func (p *AXFRProvider) FetchTargets() (config.Config, error) {
+	targets, err := FetchTargetsFromAXFR(p.Server, p.Zone, p.Key)
+	return config.Config{Targets: targets}, err
+}
+
+// 4f. Nmap / masscan import Provider
+type PortScanProvider struct {
+	Options PortScanOptions
+}
+
+func (p *PortScanProvider) FetchTargets() (config.Config, error) {
+	targets, err := FetchTargetsFromPortScan(p.Options)
+	return config.Config{Targets: targets}, err
+}
+
+// 4g. Terraform state Provider
+type TerraformProvider struct {
+	Options TerraformStateOptions
+}
+
+func (p *TerraformProvider) FetchTargets() (config.Config, error) {
+	targets, err := FetchTargetsFromTerraformState(p.Options)
+	return config.Config{Targets: targets}, err
+}
+
+// 4h. NetBox Provider (IP addresses, services and tagged prefixes)
+type NetBoxProvider struct {
+	Options NetBoxOptions
+}
+
+func (p *NetBoxProvider) FetchTargets() (config.Config, error) {
+	return FetchTargetsFromNetBox(p.Options)
+}
+
+// 4i. nginx / Apache / HAProxy configuration Provider
+type WebServerProvider struct {
+	Options WebServerOptions
+}
+
+func (p *WebServerProvider) FetchTargets() (config.Config, error) {
+	targets, results, err := FetchTargetsFromWebServerConfigs(p.Options, time.Now())
+	return config.Config{Targets: targets, Results: results}, err
+}
+
+// 4j. Docker Engine Provider (labelled containers)
+type DockerProvider struct {
+	Options DockerOptions
+}
+
+func (p *DockerProvider) FetchTargets() (config.Config, error) {
+	targets, err := FetchTargetsFromDocker(p.Options)
+	return config.Config{Targets: targets}, err
+}
+
+// 4k. Envoy / HAProxy admin API certificate inventory Provider
+type ProxyAdminProvider struct {
+	Options ProxyAdminOptions
+}
+
+func (p *ProxyAdminProvider) FetchTargets() (config.Config, error) {
+	results, err := FetchProxyAdminCertificates(p.Options, time.Now())
+	return config.Config{Results: results}, err
+}
+
+// 4l. HashiCorp Vault PKI issued-certificate Provider
+type VaultProvider struct {
+	Options VaultOptions
+}
+
+func (p *VaultProvider) FetchTargets() (config.Config, error) {
+	results, err := FetchVaultCertificates(p.Options, time.Now())
+	return config.Config{Results: results}, err
+}
+
 // 5. GitLab Provider
 type GitLabProvider struct {
-	Token, URL, ProjectID, FilePath, Ref string
+	Options GitLabOptions
 }
 
 func (p *GitLabProvider) FetchTargets() (config.Config, error) {
-	// We need to move the 'fetchGitLabConfig' logic we wrote in cert.go
-	// into a reusable function in this package, or implement it here.
-	// For now, assuming you move that helper logic to `gitlab.go` or similar:
-	return FetchGitLabConfig(p.Token, p.URL, p.ProjectID, p.FilePath, p.Ref)
+	return FetchGitLabTargets(p.Options)
+}
+
+// 5b. GitHub repository file Provider
+type GitHubProvider struct {
+	Options GitHubOptions
+}
+
+func (p *GitHubProvider) FetchTargets() (config.Config, error) {
+	return FetchGitHubConfig(p.Options)
+}
+
+// 5c. HTTP(S) URL Provider
+type HTTPConfigProvider struct {
+	Options HTTPConfigOptions
+}
+
+func (p *HTTPConfigProvider) FetchTargets() (config.Config, error) {
+	return FetchHTTPConfig(p.Options)
+}
+
+// 5d. S3 object Provider
+type S3ConfigProvider struct {
+	Options S3ConfigOptions
+}
+
+func (p *S3ConfigProvider) FetchTargets() (config.Config, error) {
+	return FetchS3Config(p.Options)
+}
+
+// discoveryError turns a non-fatal discovery failure into a result row so it
+// shows up in the output and alerts instead of aborting the run
+func discoveryError(source, subject string, labels map[string]string, err error) config.DomainValidity {
+	return config.DomainValidity{
+		Domain:          subject,
+		Source:          source,
+		Labels:          labels,
+		Error:           err.Error(),
+		DaysUntilExpiry: 999999,
+	}
 }
 
 // -- Factory --
 
-// GetProvider returns the correct provider based on configuration
+func awsAccounts(cfg *config.AppConfig) AWSAccounts {
+	return AWSAccounts{
+		IDs:              config.SplitList(cfg.AWSAccountIDs),
+		UseOrganizations: cfg.AWSOrganization,
+		RoleName:         cfg.AWSRoleName,
+	}
+}
+
+// GetProvider returns the correct provider based on configuration. Several
+// comma-separated types, or a providers file, are combined into a MultiProvider.
 func GetProvider(cfg *config.AppConfig) (TargetProvider, error) {
-	switch cfg.ConfigType {
+	if cfg.ProvidersFile != "" || len(config.SplitList(cfg.ConfigType)) > 1 {
+		return newMultiProvider(cfg)
+	}
+
+	switch strings.TrimSpace(cfg.ConfigType) {
 	case "zone":
-		return &Route53Provider{HostedZoneID: cfg.HostedZoneID}, nil
+		tags, err := config.ParseLabels(cfg.Route53Tags)
+		if err != nil {
+			return nil, fmt.Errorf("invalid route53 tag filter: %w", err)
+		}
+		return &Route53Provider{
+			HostedZoneID: cfg.HostedZoneID,
+			AllZones:     cfg.Route53AllZones,
+			ZoneFilter: Route53ZoneFilter{
+				Include:    config.SplitList(cfg.Route53Include),
+				Exclude:    config.SplitList(cfg.Route53Exclude),
+				Tags:       tags,
+				Visibility: cfg.Route53Visibility,
+			},
+			Accounts: awsAccounts(cfg),
+			Expand:   cfg.Route53ExpandRouting,
+		}, nil
+	case "acm":
+		return &AWSCertsProvider{
+			Options: AWSCertOptions{
+				Regions:  config.SplitList(cfg.AWSRegions),
+				Accounts: awsAccounts(cfg),
+			},
+		}, nil
+	case "aws-listeners":
+		return &AWSListenersProvider{
+			Regions:  config.SplitList(cfg.AWSRegions),
+			Accounts: awsAccounts(cfg),
+		}, nil
 	case "config":
 		return &FileProvider{Path: cfg.ConfigFile}, nil
 	case "cloudflare":
-		return &CloudflareProvider{Token: cfg.CloudflareToken, ZoneID: cfg.CloudflareZoneID}, nil
+		return &CloudflareProvider{
+			Token: cfg.CloudflareToken,
+			Options: CloudflareOptions{
+				ZoneID:     cfg.CloudflareZoneID,
+				AccountID:  cfg.CloudflareAccountID,
+				ZoneFilter: config.SplitList(cfg.CloudflareZones),
+				ScanOrigin: cfg.CloudflareScanOrigin,
+			},
+		}, nil
+	case "cloudflare-certs":
+		return &CloudflareCertsProvider{
+			Token: cfg.CloudflareToken,
+			Options: CloudflareOptions{
+				ZoneID:     cfg.CloudflareZoneID,
+				AccountID:  cfg.CloudflareAccountID,
+				ZoneFilter: config.SplitList(cfg.CloudflareZones),
+			},
+		}, nil
 	case "azure":
 		return &AzureProvider{
-			SubID:        cfg.AzureSubscriptionID,
-			ResGroup:     cfg.AzureResourceGroup,
-			Zone:         cfg.AzureDNSZone,
-			ClientID:     cfg.AzureClientID,
-			ClientSecret: cfg.AzureClientSecret,
-			TenantID:     cfg.AzureTenantID,
+			SubID:         cfg.AzureSubscriptionID,
+			ResGroup:      cfg.AzureResourceGroup,
+			Zone:          cfg.AzureDNSZone,
+			ClientID:      cfg.AzureClientID,
+			ClientSecret:  cfg.AzureClientSecret,
+			TenantID:      cfg.AzureTenantID,
+			Subscriptions: config.SplitList(cfg.AzureSubscriptions),
+		}, nil
+	case "gcp":
+		return &GCPProvider{
+			Projects:        config.SplitList(cfg.GCPProjects),
+			CredentialsFile: cfg.GCPCredentialsFile,
+		}, nil
+	case "kubernetes":
+		return &KubernetesProvider{
+			Options: KubernetesOptions{
+				Kubeconfig: cfg.Kubeconfig,
+				Context:    cfg.KubeContext,
+				Namespaces: config.SplitList(cfg.KubeNamespaces),
+			},
 		}, nil
+	case "zonefile":
+		return &ZoneFileProvider{Path: cfg.ZoneFile, Origin: cfg.ZoneOrigin}, nil
+	case "axfr":
+		return &AXFRProvider{
+			Server: cfg.AXFRServer,
+			Zone:   cfg.AXFRZone,
+			Key: TSIGKey{
+				Name:      cfg.TSIGName,
+				Secret:    cfg.TSIGSecret,
+				Algorithm: cfg.TSIGAlgorithm,
+			},
+		}, nil
+	case "portscan":
+		return &PortScanProvider{Options: PortScanOptions{Path: cfg.PortScanFile, AllPorts: cfg.PortScanAll}}, nil
+	case "terraform":
+		return &TerraformProvider{
+			Options: TerraformStateOptions{
+				Sources:  config.SplitList(cfg.TerraformState),
+				Username: cfg.TerraformStateUser,
+				Password: cfg.TerraformStatePassword,
+			},
+		}, nil
+	case "netbox":
+		return &NetBoxProvider{Options: NetBoxOptions{
+			URL:       cfg.NetBoxURL,
+			Token:     cfg.NetBoxToken,
+			Tags:      config.SplitList(cfg.NetBoxTags),
+			Tenants:   config.SplitList(cfg.NetBoxTenants),
+			Sites:     config.SplitList(cfg.NetBoxSites),
+			Statuses:  config.SplitList(cfg.NetBoxStatus),
+			PrefixTag: cfg.NetBoxPrefixTag,
+			CIDR:      cfg.CIDROptions(),
+		}}, nil
+	case "webserver":
+		return &WebServerProvider{Options: WebServerOptions{
+			Paths:   config.SplitList(cfg.WebServerConfigs),
+			Address: cfg.WebServerAddress,
+		}}, nil
+	case "docker":
+		return &DockerProvider{Options: DockerOptions{
+			Host:    cfg.DockerHost,
+			Address: cfg.DockerAddress,
+		}}, nil
+	case "proxyadmin":
+		return &ProxyAdminProvider{Options: ProxyAdminOptions{
+			EnvoyAdmin:     config.SplitList(cfg.EnvoyAdmin),
+			HAProxySockets: config.SplitList(cfg.HAProxySockets),
+		}}, nil
+	case "vault":
+		return &VaultProvider{Options: VaultOptions{
+			Address:      cfg.VaultAddr,
+			Token:        cfg.VaultToken,
+			RoleID:       cfg.VaultRoleID,
+			SecretID:     cfg.VaultSecretID,
+			AppRoleMount: cfg.VaultAppRoleMount,
+			Namespace:    cfg.VaultNamespace,
+			Mounts:       config.SplitList(cfg.VaultMounts),
+		}}, nil
 	case "gitlab":
-		return &GitLabProvider{
+		ports, err := config.ParsePorts(cfg.PortString)
+		if err != nil {
+			return nil, err
+		}
+		return &GitLabProvider{Options: GitLabOptions{
 			Token:     cfg.GitlabToken,
 			URL:       cfg.GitlabURL,
 			ProjectID: cfg.GitlabProjectID,
-			FilePath:  cfg.GitlabFilePath,
+			FilePaths: config.SplitList(cfg.GitlabFilePath),
 			Ref:       cfg.GitlabRef,
-		}, nil
+			Group:     cfg.GitlabGroup,
+			GroupFile: cfg.GitlabGroupFile,
+			Ports:     ports,
+		}}, nil
+	case "github":
+		return &GitHubProvider{Options: GitHubOptions{
+			Token:   cfg.GitHubToken,
+			Repo:    cfg.GitHubRepo,
+			Path:    cfg.GitHubPath,
+			Ref:     cfg.GitHubRef,
+			BaseURL: cfg.GitHubURL,
+		}}, nil
+	case "https":
+		return &HTTPConfigProvider{Options: HTTPConfigOptions{
+			URL:         cfg.ConfigURL,
+			BearerToken: cfg.ConfigURLToken,
+			Username:    cfg.ConfigURLUser,
+			Password:    cfg.ConfigURLPassword,
+			CacheDir:    cfg.ConfigURLCacheDir,
+		}}, nil
+	case "s3":
+		return &S3ConfigProvider{Options: S3ConfigOptions{
+			URL:      cfg.S3URL,
+			Region:   cfg.S3Region,
+			Endpoint: cfg.S3Endpoint,
+		}}, nil
 	default:
 		return nil, fmt.Errorf("unknown config type: %s", cfg.ConfigType)
 	}
@@ -0,0 +1,274 @@
+package discovery
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/andre/ssl-cert-test/internal/config"
+	"github.com/andre/ssl-cert-test/internal/scan"
+)
+
+// ProxyAdminOptions lists the admin endpoints to inventory. Envoy endpoints
+// are admin base URLs (http://10.0.0.1:15000); HAProxy sockets are runtime API
+// addresses, either a unix socket path or host:port for a TCP stats socket.
+type ProxyAdminOptions struct {
+	EnvoyAdmin     []string
+	HAProxySockets []string
+}
+
+// envoyCerts is the body of Envoy's /certs admin endpoint
+type envoyCerts struct {
+	Certificates []struct {
+		CACert    []envoyCertDetails `json:"ca_cert"`
+		CertChain []envoyCertDetails `json:"cert_chain"`
+	} `json:"certificates"`
+}
+
+type envoyCertDetails struct {
+	Path            string `json:"path"`
+	SerialNumber    string `json:"serial_number"`
+	SubjectAltNames []struct {
+		DNS string `json:"dns"`
+		URI string `json:"uri"`
+		IP  string `json:"ip_address"`
+	} `json:"subject_alt_names"`
+	ValidFrom      time.Time `json:"valid_from"`
+	ExpirationTime time.Time `json:"expiration_time"`
+}
+
+// haproxyTimeLayout is how the runtime API prints notBefore and notAfter
+const haproxyTimeLayout = "Jan _2 15:04:05 2006 MST"
+
+// FetchProxyAdminCertificates asks each Envoy admin endpoint and HAProxy
+// runtime socket for the certificates it has loaded and returns one row per
+// certificate. This reaches mesh and internal certificates that no external
+// handshake can. An unreachable endpoint becomes an error row.
+func FetchProxyAdminCertificates(opts ProxyAdminOptions, now time.Time) ([]config.DomainValidity, error) {
+	if len(opts.EnvoyAdmin) == 0 && len(opts.HAProxySockets) == 0 {
+		return nil, fmt.Errorf("at least one envoy admin url or haproxy socket is required")
+	}
+
+	var results []config.DomainValidity
+	client := &http.Client{Timeout: 15 * time.Second}
+	for _, endpoint := range opts.EnvoyAdmin {
+		rows, err := fetchEnvoyCerts(client, endpoint, now)
+		if err != nil {
+			rows = []config.DomainValidity{discoveryError("envoy", endpoint, map[string]string{"envoy_admin": endpoint}, err)}
+		}
+		results = append(results, rows...)
+	}
+	for _, socket := range opts.HAProxySockets {
+		rows, err := fetchHAProxyCerts(socket, now)
+		if err != nil {
+			rows = []config.DomainValidity{discoveryError("haproxy", socket, map[string]string{"haproxy_socket": socket}, err)}
+		}
+		results = append(results, rows...)
+	}
+	return results, nil
+}
+
+func fetchEnvoyCerts(client *http.Client, endpoint string, now time.Time) ([]config.DomainValidity, error) {
+	resp, err := client.Get(strings.TrimSuffix(endpoint, "/") + "/certs")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query envoy admin: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("envoy admin returned status %d", resp.StatusCode)
+	}
+
+	var body envoyCerts
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to parse envoy certs: %w", err)
+	}
+
+	var results []config.DomainValidity
+	for _, c := range body.Certificates {
+		for role, chain := range [][]envoyCertDetails{c.CertChain, c.CACert} {
+			for _, d := range chain {
+				var sans []string
+				for _, san := range d.SubjectAltNames {
+					switch {
+					case san.DNS != "":
+						sans = append(sans, san.DNS)
+					case san.URI != "":
+						sans = append(sans, san.URI) // SPIFFE IDs of mesh certificates
+					case san.IP != "":
+						sans = append(sans, san.IP)
+					}
+				}
+
+				domain := d.Path
+				if len(sans) > 0 {
+					domain = sans[0]
+				}
+				labels := map[string]string{"envoy_admin": endpoint, "cert_role": "cert_chain"}
+				if role == 1 {
+					labels["cert_role"] = "ca_cert"
+				}
+				if d.Path != "" {
+					labels["envoy_cert_path"] = d.Path
+				}
+
+				results = append(results, config.DomainValidity{
+					Domain:          domain,
+					Serial:          normalizeSerial(d.SerialNumber),
+					SANs:            sans,
+					NotBefore:       d.ValidFrom,
+					NotAfter:        d.ExpirationTime,
+					DaysUntilExpiry: scan.DaysUntil(d.ExpirationTime, now),
+					Source:          "envoy",
+					Labels:          labels,
+				})
+			}
+		}
+	}
+	return results, nil
+}
+
+// fetchHAProxyCerts lists the loaded certificates with "show ssl cert", then
+// asks for each one's details. The runtime API closes the connection after
+// every command, so each command gets its own.
+func fetchHAProxyCerts(socket string, now time.Time) ([]config.DomainValidity, error) {
+	list, err := haproxyCommand(socket, "show ssl cert")
+	if err != nil {
+		return nil, err
+	}
+
+	var results []config.DomainValidity
+	for _, line := range strings.Split(list, "\n") {
+		name := strings.TrimSpace(line)
+		if name == "" || strings.HasPrefix(name, "#") {
+			continue
+		}
+		// Certificates of an uncommitted transaction are prefixed with '*'
+		if strings.HasPrefix(name, "*") {
+			continue
+		}
+
+		labels := map[string]string{"haproxy_socket": socket, "haproxy_cert": name}
+		detail, err := haproxyCommand(socket, "show ssl cert "+name)
+		if err != nil {
+			results = append(results, discoveryError("haproxy", name, labels, err))
+			continue
+		}
+		row, err := parseHAProxyCert(detail, now)
+		if err != nil {
+			results = append(results, discoveryError("haproxy", name, labels, err))
+			continue
+		}
+		row.Labels = labels
+		if row.Domain == "" {
+			row.Domain = name
+		}
+		results = append(results, row)
+	}
+	return results, nil
+}
+
+// parseHAProxyCert reads the "Key: value" lines of one certificate's details
+func parseHAProxyCert(detail string, now time.Time) (config.DomainValidity, error) {
+	row := config.DomainValidity{Source: "haproxy"}
+	scanner := bufio.NewScanner(strings.NewReader(detail))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ": ")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "Serial":
+			row.Serial = normalizeSerial(value)
+		case "notBefore":
+			row.NotBefore, _ = time.Parse(haproxyTimeLayout, value)
+		case "notAfter":
+			t, err := time.Parse(haproxyTimeLayout, value)
+			if err != nil {
+				return row, fmt.Errorf("invalid notAfter %q: %w", value, err)
+			}
+			row.NotAfter = t
+		case "Subject Alternative Name":
+			for _, san := range strings.Split(value, ",") {
+				if _, name, ok := strings.Cut(strings.TrimSpace(san), ":"); ok {
+					row.SANs = append(row.SANs, name)
+				}
+			}
+		case "Subject":
+			row.CommonName = opensslField(value, "CN")
+		case "Issuer":
+			row.Issuer = opensslField(value, "CN")
+			if row.Issuer == "" {
+				row.Issuer = opensslField(value, "O")
+			}
+		}
+	}
+	if row.NotAfter.IsZero() {
+		return row, fmt.Errorf("no certificate details returned")
+	}
+
+	row.DaysUntilExpiry = scan.DaysUntil(row.NotAfter, now)
+	row.Domain = row.CommonName
+	if row.Domain == "" && len(row.SANs) > 0 {
+		row.Domain = row.SANs[0]
+	}
+	return row, nil
+}
+
+// haproxyCommand sends one runtime API command and returns the full reply
+func haproxyCommand(socket, command string) (string, error) {
+	network, address := "tcp", socket
+	if strings.HasPrefix(socket, "/") || strings.HasPrefix(socket, "unix://") {
+		network, address = "unix", strings.TrimPrefix(socket, "unix://")
+	}
+
+	conn, err := net.DialTimeout(network, address, 10*time.Second)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to haproxy runtime api: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(15 * time.Second))
+
+	if _, err := io.WriteString(conn, command+"\n"); err != nil {
+		return "", fmt.Errorf("failed to send haproxy command: %w", err)
+	}
+	reply, err := io.ReadAll(conn)
+	if err != nil {
+		return "", fmt.Errorf("failed to read haproxy reply: %w", err)
+	}
+	// Errors come back as plain text rather than a status
+	text := string(reply)
+	if strings.HasPrefix(text, "Unknown command") || strings.HasPrefix(text, "Permission denied") {
+		return "", fmt.Errorf("haproxy: %s", strings.TrimSpace(text))
+	}
+	return text, nil
+}
+
+// opensslField extracts one attribute from an OpenSSL one-line name such as
+// "/C=US/O=DigiCert Inc/CN=RapidSSL TLS RSA CA G1"
+func opensslField(name, attr string) string {
+	for _, part := range strings.Split(name, "/") {
+		if k, v, ok := strings.Cut(part, "="); ok && k == attr {
+			return v
+		}
+	}
+	return ""
+}
+
+// normalizeSerial formats a hex serial the way handshake results do:
+// lowercase, without separators or leading zeros
+func normalizeSerial(s string) string {
+	if s == "" {
+		return ""
+	}
+	s = strings.TrimLeft(strings.ToLower(strings.ReplaceAll(s, ":", "")), "0")
+	if s == "" {
+		return "0"
+	}
+	return s
+}
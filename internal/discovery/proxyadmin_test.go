@@ -0,0 +1,124 @@
+package discovery
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const envoyCertsJSON = `{
+  "certificates": [
+    {
+      "ca_cert": [
+        {"path": "/etc/istio/root-cert.pem", "serial_number": "00ab12", "subject_alt_names": [],
+         "valid_from": "2025-01-01T00:00:00Z", "expiration_time": "2035-01-01T00:00:00Z"}
+      ],
+      "cert_chain": [
+        {"path": "<inline>", "serial_number": "7F3C",
+         "subject_alt_names": [{"uri": "spiffe://cluster.local/ns/shop/sa/checkout"}],
+         "valid_from": "2026-10-17T00:00:00Z", "expiration_time": "2026-10-19T12:00:00Z"}
+      ]
+    }
+  ]
+}`
+
+// fakeHAProxy answers runtime API commands on a unix socket, one per connection
+func fakeHAProxy(t *testing.T, replies map[string]string) string {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "admin.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			command, _ := bufio.NewReader(conn).ReadString('\n')
+			reply, ok := replies[strings.TrimSpace(command)]
+			if !ok {
+				reply = "Unknown command: '" + strings.TrimSpace(command) + "'\n"
+			}
+			conn.Write([]byte(reply))
+			conn.Close()
+		}
+	}()
+	return socket
+}
+
+func TestFetchProxyAdminCertificates(t *testing.T) {
+	now := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+
+	envoy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/certs", r.URL.Path)
+		w.Write([]byte(envoyCertsJSON))
+	}))
+	defer envoy.Close()
+
+	socket := fakeHAProxy(t, map[string]string{
+		"show ssl cert": "# transaction\n*/etc/haproxy/certs/new.pem\n# filename\n/etc/haproxy/certs/site.pem\n/etc/haproxy/certs/broken.pem\n",
+		"show ssl cert /etc/haproxy/certs/site.pem": `Filename: /etc/haproxy/certs/site.pem
+Status: Used
+Serial: 0D933C1B1089BF660AE5253A245BB388
+notBefore: Sep  9 00:00:00 2026 GMT
+notAfter: Nov 14 12:00:00 2026 GMT
+Subject Alternative Name: DNS:example.com, DNS:*.example.com
+Algorithm: RSA2048
+Subject: /C=FR/O=Example/CN=example.com
+Issuer: /C=US/O=DigiCert Inc/CN=RapidSSL TLS RSA CA G1
+`,
+	})
+
+	results, err := FetchProxyAdminCertificates(ProxyAdminOptions{
+		EnvoyAdmin:     []string{envoy.URL + "/", "http://127.0.0.1:1"},
+		HAProxySockets: []string{socket},
+	}, now)
+	assert.NoError(t, err)
+	assert.Len(t, results, 5)
+
+	workload := results[0]
+	assert.Equal(t, "spiffe://cluster.local/ns/shop/sa/checkout", workload.Domain)
+	assert.Equal(t, "7f3c", workload.Serial)
+	assert.Equal(t, 1, workload.DaysUntilExpiry)
+	assert.Equal(t, "envoy", workload.Source)
+	assert.Equal(t, "cert_chain", workload.Labels["cert_role"])
+
+	root := results[1]
+	assert.Equal(t, "/etc/istio/root-cert.pem", root.Domain, "certificates without SANs are named by path")
+	assert.Equal(t, "ab12", root.Serial)
+	assert.Equal(t, "ca_cert", root.Labels["cert_role"])
+
+	assert.Equal(t, "http://127.0.0.1:1", results[2].Domain)
+	assert.Contains(t, results[2].Error, "failed to query envoy admin")
+	assert.Equal(t, 999999, results[2].DaysUntilExpiry)
+
+	site := results[3]
+	assert.Equal(t, "example.com", site.Domain)
+	assert.Equal(t, "d933c1b1089bf660ae5253a245bb388", site.Serial)
+	assert.Equal(t, []string{"example.com", "*.example.com"}, site.SANs)
+	assert.Equal(t, "RapidSSL TLS RSA CA G1", site.Issuer)
+	assert.Equal(t, time.Date(2026, 11, 14, 12, 0, 0, 0, time.UTC), site.NotAfter.UTC())
+	assert.Equal(t, 27, site.DaysUntilExpiry)
+	assert.Equal(t, "haproxy", site.Source)
+	assert.Equal(t, "/etc/haproxy/certs/site.pem", site.Labels["haproxy_cert"])
+
+	broken := results[4]
+	assert.Equal(t, "/etc/haproxy/certs/broken.pem", broken.Domain)
+	assert.Contains(t, broken.Error, "Unknown command")
+}
+
+func TestFetchProxyAdminCertificates_NoEndpoints(t *testing.T) {
+	_, err := FetchProxyAdminCertificates(ProxyAdminOptions{}, time.Now())
+	assert.Error(t, err)
+}
@@ -2,29 +2,222 @@ package discovery
 
 import (
 	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/andre/ssl-cert-test/internal/config"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
 )
 
+// Route53ZoneFilter selects which hosted zones are walked when enumerating an account
+type Route53ZoneFilter struct {
+	Include    []string          // Zone name globs to keep (empty keeps everything)
+	Exclude    []string          // Zone name globs to drop
+	Tags       map[string]string // Tags a zone must carry
+	Visibility string            // "all", "public" or "private"
+}
+
+// FetchDomainsFromRoute53 returns the normalized, deduplicated A/AAAA/CNAME names of a single zone
 func FetchDomainsFromRoute53(hostedZoneID string) ([]string, error) {
-	sess, err := session.NewSessionWithOptions(session.Options{
-		SharedConfigState: session.SharedConfigEnable,
+	targets, err := FetchTargetsFromRoute53(hostedZoneID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	domains := make([]string, 0, len(targets))
+	for _, t := range targets {
+		domains = append(domains, t.Host)
+	}
+	return domains, nil
+}
+
+// FetchTargetsFromRoute53 returns the targets of a single zone. With expand set,
+// every value of a weighted/latency/failover/geo record set becomes its own target.
+func FetchTargetsFromRoute53(hostedZoneID string, expand bool) ([]config.Target, error) {
+	sess, err := newAWSSession()
+	if err != nil {
+		return nil, err
+	}
+
+	return fetchRoute53Records(route53.New(sess), hostedZoneID, expand)
+}
+
+// FetchTargetsFromRoute53Zones walks every hosted zone matching the filter and
+// tags each discovered name with the zone it came from
+func FetchTargetsFromRoute53Zones(filter Route53ZoneFilter, expand bool) ([]config.Target, error) {
+	sess, err := newAWSSession()
+	if err != nil {
+		return nil, err
+	}
+
+	return route53ZoneTargets(route53.New(sess), filter, expand)
+}
+
+// FetchTargetsFromRoute53Accounts walks the hosted zones of every selected account
+// through an assumed role and tags each name with its account. Accounts that fail
+// are returned as error rows instead of aborting the run.
+func FetchTargetsFromRoute53Accounts(accounts AWSAccounts, filter Route53ZoneFilter, expand bool) ([]config.Target, []config.DomainValidity, error) {
+	var targets []config.Target
+
+	failures, err := forEachAWSAccount(accounts, "route53", func(accountID string, sess *session.Session) error {
+		found, err := route53ZoneTargets(route53.New(sess), filter, expand)
+		if err != nil {
+			return err
+		}
+		for _, t := range found {
+			t.Labels["aws_account"] = accountID
+			targets = append(targets, t)
+		}
+		return nil
+	})
+
+	return targets, failures, err
+}
+
+// route53ZoneTargets walks every zone passing the filter and labels each name with its zone
+func route53ZoneTargets(svc route53iface.Route53API, filter Route53ZoneFilter, expand bool) ([]config.Target, error) {
+	zones, err := listRoute53Zones(svc, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []config.Target
+	for _, zone := range zones {
+		zoneID := strings.TrimPrefix(aws.StringValue(zone.Id), "/hostedzone/")
+		private := zone.Config != nil && aws.BoolValue(zone.Config.PrivateZone)
+
+		found, err := fetchRoute53Records(svc, zoneID, expand)
+		if err != nil {
+			return nil, fmt.Errorf("zone %s: %w", zoneID, err)
+		}
+
+		for _, t := range found {
+			t.Labels["route53_zone"] = config.NormalizeHost(aws.StringValue(zone.Name))
+			t.Labels["route53_zone_id"] = zoneID
+			t.Labels["route53_private"] = fmt.Sprint(private)
+			targets = append(targets, t)
+		}
+	}
+
+	return targets, nil
+}
+
+// listRoute53Zones returns the hosted zones in the account that pass the filter
+func listRoute53Zones(svc route53iface.Route53API, filter Route53ZoneFilter) ([]*route53.HostedZone, error) {
+	switch filter.Visibility {
+	case "", "all", "public", "private":
+	default:
+		return nil, fmt.Errorf("invalid route53 zone visibility: %s", filter.Visibility)
+	}
+
+	var zones []*route53.HostedZone
+	err := svc.ListHostedZonesPages(&route53.ListHostedZonesInput{}, func(page *route53.ListHostedZonesOutput, lastPage bool) bool {
+		for _, zone := range page.HostedZones {
+			if route53ZoneSelected(zone, filter) {
+				zones = append(zones, zone)
+			}
+		}
+		return !lastPage
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create AWS session: %v", err)
+		return nil, fmt.Errorf("failed to list hosted zones: %v", err)
 	}
 
-	svc := route53.New(sess)
+	if len(filter.Tags) == 0 {
+		return zones, nil
+	}
+
+	// Tags are not part of the zone listing, so fetch them only when filtering on them
+	var tagged []*route53.HostedZone
+	for _, zone := range zones {
+		out, err := svc.ListTagsForResource(&route53.ListTagsForResourceInput{
+			ResourceId:   aws.String(strings.TrimPrefix(aws.StringValue(zone.Id), "/hostedzone/")),
+			ResourceType: aws.String(route53.TagResourceTypeHostedzone),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tags for zone %s: %v", aws.StringValue(zone.Id), err)
+		}
+
+		tags := make(map[string]string)
+		if out.ResourceTagSet != nil {
+			for _, tag := range out.ResourceTagSet.Tags {
+				tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+			}
+		}
+		if hasLabels(tags, filter.Tags) {
+			tagged = append(tagged, zone)
+		}
+	}
+	return tagged, nil
+}
+
+// route53ZoneSelected applies the name and visibility parts of the filter
+func route53ZoneSelected(zone *route53.HostedZone, filter Route53ZoneFilter) bool {
+	private := zone.Config != nil && aws.BoolValue(zone.Config.PrivateZone)
+	if (filter.Visibility == "public" && private) || (filter.Visibility == "private" && !private) {
+		return false
+	}
+
+	name := strings.TrimSuffix(aws.StringValue(zone.Name), ".")
+	if len(filter.Include) > 0 && !matchesAny(name, filter.Include) {
+		return false
+	}
+	return !matchesAny(name, filter.Exclude)
+}
+
+// fetchRoute53Records turns the A, AAAA and CNAME record sets (including aliases) of a
+// zone into targets, deduplicated by name (or by name and value when expanding)
+func fetchRoute53Records(svc route53iface.Route53API, hostedZoneID string, expand bool) ([]config.Target, error) {
 	input := &route53.ListResourceRecordSetsInput{
 		HostedZoneId: aws.String(hostedZoneID),
 	}
-	var domains []string
+	var targets []config.Target
+	seen := make(map[string]bool)
 
-	err = svc.ListResourceRecordSetsPages(input, func(rrPage *route53.ListResourceRecordSetsOutput, lastPage bool) bool {
+	add := func(name, address string, labels map[string]string) {
+		key := name + "|" + address
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		targets = append(targets, config.Target{Host: name, Address: address, Labels: labels})
+	}
+
+	err := svc.ListResourceRecordSetsPages(input, func(rrPage *route53.ListResourceRecordSetsOutput, lastPage bool) bool {
 		for _, record := range rrPage.ResourceRecordSets {
-			if aws.StringValue(record.Type) == "A" || aws.StringValue(record.Type) == "CNAME" {
-				domains = append(domains, aws.StringValue(record.Name))
+			switch aws.StringValue(record.Type) {
+			case "A", "AAAA", "CNAME":
+			default:
+				continue
+			}
+
+			name := normalizeRoute53Name(aws.StringValue(record.Name))
+			if strings.HasPrefix(name, "*.") {
+				// Wildcards cannot be dialed or sent as SNI
+				continue
+			}
+
+			if !expand || record.SetIdentifier == nil {
+				add(name, "", map[string]string{})
+				continue
+			}
+
+			// Routing policy record sets share a name, so scan each backend with the name as SNI
+			labels := func() map[string]string {
+				return map[string]string{
+					"route53_set_id":  aws.StringValue(record.SetIdentifier),
+					"route53_routing": route53RoutingPolicy(record),
+				}
+			}
+			if record.AliasTarget != nil {
+				add(name, normalizeRoute53Name(aws.StringValue(record.AliasTarget.DNSName)), labels())
+			}
+			for _, rr := range record.ResourceRecords {
+				add(name, normalizeRoute53Name(aws.StringValue(rr.Value)), labels())
 			}
 		}
 		return !lastPage
@@ -33,5 +226,64 @@ func FetchDomainsFromRoute53(hostedZoneID string) ([]string, error) {
 		return nil, fmt.Errorf("failed to list resource record sets: %v", err)
 	}
 
-	return domains, nil
+	return targets, nil
+}
+
+// route53RoutingPolicy names the routing policy of a record set that has a SetIdentifier
+func route53RoutingPolicy(record *route53.ResourceRecordSet) string {
+	switch {
+	case record.Weight != nil:
+		return "weighted"
+	case record.Region != nil:
+		return "latency"
+	case record.Failover != nil:
+		return "failover"
+	case record.GeoLocation != nil:
+		return "geolocation"
+	case record.GeoProximityLocation != nil:
+		return "geoproximity"
+	case record.CidrRoutingConfig != nil:
+		return "cidr"
+	case aws.BoolValue(record.MultiValueAnswer):
+		return "multivalue"
+	default:
+		return "unknown"
+	}
+}
+
+// normalizeRoute53Name decodes the octal escapes Route53 uses (e.g. \052 for *)
+// and returns the name without its trailing dot
+func normalizeRoute53Name(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' && i+3 < len(name) {
+			if n, err := strconv.ParseUint(name[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(name[i])
+	}
+	return config.NormalizeHost(b.String())
+}
+
+// matchesAny reports whether name matches one of the glob patterns (case-insensitive)
+func matchesAny(name string, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(strings.ToLower(p), strings.ToLower(name)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hasLabels reports whether every wanted key/value pair is present in labels
+func hasLabels(labels, want map[string]string) bool {
+	for k, v := range want {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
 }
@@ -0,0 +1,230 @@
+package discovery
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRoute53 serves the handful of Route53 REST/XML calls used by discovery
+type fakeRoute53 struct {
+	zones   map[string]string // zone ID -> ListHostedZones <HostedZone> body
+	records map[string]string // zone ID -> <ResourceRecordSet> bodies
+	tags    map[string]string // zone ID -> <Tag> bodies
+}
+
+func (f *fakeRoute53) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/xml")
+	p := strings.TrimPrefix(r.URL.Path, "/2013-04-01/")
+
+	switch {
+	case p == "hostedzone":
+		var zones strings.Builder
+		for _, z := range f.zones {
+			zones.WriteString(z)
+		}
+		fmt.Fprintf(w, `<ListHostedZonesResponse><HostedZones>%s</HostedZones><IsTruncated>false</IsTruncated><MaxItems>100</MaxItems></ListHostedZonesResponse>`, zones.String())
+	case strings.HasPrefix(p, "hostedzone/") && strings.HasSuffix(p, "/rrset"):
+		id := strings.TrimSuffix(strings.TrimPrefix(p, "hostedzone/"), "/rrset")
+		fmt.Fprintf(w, `<ListResourceRecordSetsResponse><ResourceRecordSets>%s</ResourceRecordSets><IsTruncated>false</IsTruncated><MaxItems>100</MaxItems></ListResourceRecordSetsResponse>`, f.records[id])
+	case strings.HasPrefix(p, "tags/hostedzone/"):
+		id := strings.TrimPrefix(p, "tags/hostedzone/")
+		fmt.Fprintf(w, `<ListTagsForResourceResponse><ResourceTagSet><ResourceType>hostedzone</ResourceType><ResourceId>%s</ResourceId><Tags>%s</Tags></ResourceTagSet></ListTagsForResourceResponse>`, id, f.tags[id])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func r53Zone(id, name string, private bool) string {
+	return fmt.Sprintf(`<HostedZone><Id>/hostedzone/%s</Id><Name>%s</Name><CallerReference>ref</CallerReference><Config><PrivateZone>%t</PrivateZone></Config></HostedZone>`, id, name, private)
+}
+
+func r53Record(name, rrType, value string) string {
+	return fmt.Sprintf(`<ResourceRecordSet><Name>%s</Name><Type>%s</Type><TTL>300</TTL><ResourceRecords><ResourceRecord><Value>%s</Value></ResourceRecord></ResourceRecords></ResourceRecordSet>`, name, rrType, value)
+}
+
+func newFakeRoute53() *fakeRoute53 {
+	return &fakeRoute53{
+		zones: map[string]string{
+			"ZPUB":  r53Zone("ZPUB", "example.com.", false),
+			"ZPRIV": r53Zone("ZPRIV", "corp.internal.", true),
+			"ZDEV":  r53Zone("ZDEV", "dev.example.org.", false),
+		},
+		records: map[string]string{
+			"ZPUB":  r53Record("www.example.com.", "A", "1.2.3.4") + r53Record("example.com.", "MX", "10 mail"),
+			"ZPRIV": r53Record("db.corp.internal.", "CNAME", "db.aws.internal"),
+			"ZDEV":  r53Record("app.dev.example.org.", "A", "5.6.7.8"),
+		},
+		tags: map[string]string{
+			"ZPUB": `<Tag><Key>env</Key><Value>prod</Value></Tag>`,
+			"ZDEV": `<Tag><Key>env</Key><Value>dev</Value></Tag>`,
+		},
+	}
+}
+
+func TestFetchDomainsFromRoute53(t *testing.T) {
+	useFakeAWS(t, newFakeRoute53())
+
+	domains, err := FetchDomainsFromRoute53("ZPUB")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"www.example.com"}, domains)
+}
+
+func TestFetchTargetsFromRoute53Zones(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Route53ZoneFilter
+		want   []string
+	}{
+		{
+			name:   "All zones",
+			filter: Route53ZoneFilter{},
+			want:   []string{"www.example.com", "db.corp.internal", "app.dev.example.org"},
+		},
+		{
+			name:   "Private only",
+			filter: Route53ZoneFilter{Visibility: "private"},
+			want:   []string{"db.corp.internal"},
+		},
+		{
+			name:   "Include and exclude globs",
+			filter: Route53ZoneFilter{Include: []string{"*.com", "*.org"}, Exclude: []string{"dev.*"}},
+			want:   []string{"www.example.com"},
+		},
+		{
+			name:   "Tag filter",
+			filter: Route53ZoneFilter{Tags: map[string]string{"env": "dev"}},
+			want:   []string{"app.dev.example.org"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeAWS(t, newFakeRoute53())
+
+			targets, err := FetchTargetsFromRoute53Zones(tt.filter, false)
+
+			assert.NoError(t, err)
+			var hosts []string
+			for _, target := range targets {
+				hosts = append(hosts, target.Host)
+			}
+			assert.ElementsMatch(t, tt.want, hosts)
+		})
+	}
+}
+
+func TestFetchTargetsFromRoute53Zones_Labels(t *testing.T) {
+	useFakeAWS(t, newFakeRoute53())
+
+	targets, err := FetchTargetsFromRoute53Zones(Route53ZoneFilter{Visibility: "private"}, false)
+
+	assert.NoError(t, err)
+	assert.Len(t, targets, 1)
+	assert.Equal(t, map[string]string{
+		"route53_zone":    "corp.internal",
+		"route53_zone_id": "ZPRIV",
+		"route53_private": "true",
+	}, targets[0].Labels)
+}
+
+func TestFetchTargetsFromRoute53Zones_InvalidVisibility(t *testing.T) {
+	useFakeAWS(t, newFakeRoute53())
+
+	_, err := FetchTargetsFromRoute53Zones(Route53ZoneFilter{Visibility: "secret"}, false)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid route53 zone visibility")
+}
+
+func TestFetchTargetsFromRoute53Accounts(t *testing.T) {
+	// Each account sees a different set of zones, keyed off the assumed-role credentials
+	perAccount := map[string]*fakeRoute53{
+		"111111111111": newFakeRoute53(),
+		"333333333333": {
+			zones:   map[string]string{"ZOTHER": r53Zone("ZOTHER", "other.net.", false)},
+			records: map[string]string{"ZOTHER": r53Record("api.other.net.", "A", "9.9.9.9")},
+		},
+	}
+	useFakeAWS(t, &fakeAWS{
+		denied: map[string]bool{"222222222222": true},
+		rest: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fake, ok := perAccount[callerAccount(r)]
+			if !ok {
+				http.Error(w, "unknown account", http.StatusForbidden)
+				return
+			}
+			fake.ServeHTTP(w, r)
+		}),
+	})
+
+	targets, failures, err := FetchTargetsFromRoute53Accounts(AWSAccounts{
+		IDs:      []string{"111111111111", "222222222222", "333333333333"},
+		RoleName: "Route53Reader",
+	}, Route53ZoneFilter{Visibility: "public"}, false)
+
+	assert.NoError(t, err)
+	got := make(map[string]string)
+	for _, target := range targets {
+		got[target.Host] = target.Labels["aws_account"]
+	}
+	assert.Equal(t, map[string]string{
+		"www.example.com":     "111111111111",
+		"app.dev.example.org": "111111111111",
+		"api.other.net":       "333333333333",
+	}, got)
+
+	assert.Len(t, failures, 1)
+	assert.Equal(t, "route53", failures[0].Source)
+	assert.NotEmpty(t, failures[0].Error)
+}
+
+func TestFetchTargetsFromRoute53_RecordTypes(t *testing.T) {
+	weighted := func(id, weight, value string) string {
+		return fmt.Sprintf(`<ResourceRecordSet><Name>api.example.com.</Name><Type>A</Type><SetIdentifier>%s</SetIdentifier><Weight>%s</Weight><TTL>60</TTL><ResourceRecords><ResourceRecord><Value>%s</Value></ResourceRecord></ResourceRecords></ResourceRecordSet>`, id, weight, value)
+	}
+	alias := `<ResourceRecordSet><Name>app.example.com.</Name><Type>AAAA</Type><SetIdentifier>eu</SetIdentifier><Region>eu-west-1</Region><AliasTarget><HostedZoneId>ZELB</HostedZoneId><DNSName>dualstack.EU-LB.elb.amazonaws.com.</DNSName><EvaluateTargetHealth>false</EvaluateTargetHealth></AliasTarget></ResourceRecordSet>`
+
+	fake := &fakeRoute53{records: map[string]string{
+		"ZPUB": weighted("us", "10", "1.1.1.1") + weighted("eu", "20", "2.2.2.2") + alias +
+			r53Record("WWW.Example.com.", "AAAA", "2001:db8::1") +
+			r53Record("www.example.com.", "A", "1.2.3.4") +
+			r53Record(`\052.example.com.`, "CNAME", "lb.example.net") +
+			r53Record("example.com.", "TXT", "v=spf1"),
+	}}
+
+	t.Run("Dedupe by name", func(t *testing.T) {
+		useFakeAWS(t, fake)
+
+		targets, err := FetchTargetsFromRoute53("ZPUB", false)
+
+		assert.NoError(t, err)
+		var hosts []string
+		for _, target := range targets {
+			assert.Empty(t, target.Address)
+			hosts = append(hosts, target.Host)
+		}
+		assert.ElementsMatch(t, []string{"api.example.com", "app.example.com", "www.example.com"}, hosts)
+	})
+
+	t.Run("Expand routing policies", func(t *testing.T) {
+		useFakeAWS(t, fake)
+
+		targets, err := FetchTargetsFromRoute53("ZPUB", true)
+
+		assert.NoError(t, err)
+		got := make(map[string]string)
+		for _, target := range targets {
+			got[target.Host+"@"+target.Address] = target.Labels["route53_routing"]
+		}
+		assert.Equal(t, map[string]string{
+			"api.example.com@1.1.1.1":                           "weighted",
+			"api.example.com@2.2.2.2":                           "weighted",
+			"app.example.com@dualstack.eu-lb.elb.amazonaws.com": "latency",
+			"www.example.com@":                                  "",
+		}, got)
+	})
+}
@@ -0,0 +1,60 @@
+package discovery
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/andre/ssl-cert-test/internal/config"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// S3ConfigOptions points at a target file in S3 or an S3-compatible store such
+// as MinIO, reached through Endpoint with path-style addressing
+type S3ConfigOptions struct {
+	URL      string // s3://bucket/key
+	Region   string
+	Endpoint string
+}
+
+// FetchS3Config downloads a JSON or YAML target file and parses it like a local
+// config file. Credentials come from the default AWS chain.
+func FetchS3Config(opts S3ConfigOptions) (config.Config, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil || u.Scheme != "s3" || u.Host == "" || strings.TrimPrefix(u.Path, "/") == "" {
+		return config.Config{}, fmt.Errorf("invalid s3 url (want s3://bucket/key): %s", opts.URL)
+	}
+	bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+
+	sess, err := newAWSSession()
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	cfg := aws.NewConfig()
+	if opts.Region != "" {
+		cfg = cfg.WithRegion(opts.Region)
+	} else if aws.StringValue(sess.Config.Region) == "" {
+		cfg = cfg.WithRegion("us-east-1")
+	}
+	if opts.Endpoint != "" {
+		cfg = cfg.WithEndpoint(opts.Endpoint).WithS3ForcePathStyle(true)
+	}
+
+	out, err := s3.New(sess, cfg).GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return config.Config{}, fmt.Errorf("failed to fetch %s: %w", opts.URL, err)
+	}
+	defer out.Body.Close()
+
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return config.Config{}, fmt.Errorf("failed to read %s: %w", opts.URL, err)
+	}
+	return config.ParseConfig(data, key)
+}
@@ -0,0 +1,41 @@
+package discovery
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchS3Config(t *testing.T) {
+	// A MinIO-style endpoint: path-style bucket addressing, any credentials
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Contains(t, r.Header.Get("Authorization"), "Credential=minio/")
+		switch r.URL.Path {
+		case "/certs/teams/payments.yaml":
+			fmt.Fprint(w, "version: 2\ntargets:\n  - host: pay.example.com\n    owner: team-payments\n")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+		}
+	}))
+	defer ts.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "minio")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "minio123")
+	t.Setenv("AWS_CONFIG_FILE", "/dev/null")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/dev/null")
+
+	conf, err := FetchS3Config(S3ConfigOptions{URL: "s3://certs/teams/payments.yaml", Endpoint: ts.URL})
+	assert.NoError(t, err)
+	assert.Len(t, conf.Targets, 1)
+	assert.Equal(t, "team-payments", conf.Targets[0].Owner)
+
+	_, err = FetchS3Config(S3ConfigOptions{URL: "s3://certs/missing.json", Endpoint: ts.URL})
+	assert.ErrorContains(t, err, "NoSuchKey")
+
+	_, err = FetchS3Config(S3ConfigOptions{URL: "s3://certs"})
+	assert.ErrorContains(t, err, "want s3://bucket/key")
+}
@@ -0,0 +1,241 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/andre/ssl-cert-test/internal/config"
+	"github.com/andre/ssl-cert-test/internal/scan"
+)
+
+// TerraformStateOptions lists the state files to read; http(s) URLs are fetched
+// like Terraform's http backend, optionally with basic auth
+type TerraformStateOptions struct {
+	Sources  []string
+	Username string
+	Password string
+}
+
+// terraformState is the subset of the v4 state format we read
+type terraformState struct {
+	Version   int `json:"version"`
+	Resources []struct {
+		Module    string `json:"module"`
+		Mode      string `json:"mode"`
+		Type      string `json:"type"`
+		Name      string `json:"name"`
+		Instances []struct {
+			IndexKey   interface{}            `json:"index_key"`
+			Attributes map[string]interface{} `json:"attributes"`
+		} `json:"instances"`
+	} `json:"resources"`
+}
+
+// terraformResource is one managed resource instance with its full address
+type terraformResource struct {
+	Address    string
+	Type       string
+	Attributes map[string]interface{}
+}
+
+// FetchTargetsFromTerraformState extracts DNS record names, TLS load balancer
+// listeners and certificate domains from Terraform state, labelling each target
+// with the resource address it came from
+func FetchTargetsFromTerraformState(opts TerraformStateOptions) ([]config.Target, error) {
+	if len(opts.Sources) == 0 {
+		return nil, fmt.Errorf("at least one terraform state file or URL is required")
+	}
+
+	var targets []config.Target
+	for _, source := range opts.Sources {
+		data, err := readTerraformState(source, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		resources, err := parseTerraformState(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", source, err)
+		}
+
+		for _, t := range terraformTargets(resources) {
+			t.Labels["terraform_state"] = source
+			targets = append(targets, t)
+		}
+	}
+	return targets, nil
+}
+
+func readTerraformState(source string, opts TerraformStateOptions) ([]byte, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read terraform state: %w", err)
+		}
+		return data, nil
+	}
+
+	req, err := http.NewRequest("GET", source, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid terraform state url: %w", err)
+	}
+	if opts.Username != "" || opts.Password != "" {
+		req.SetBasicAuth(opts.Username, opts.Password)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch terraform state: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch terraform state from %s: status %d", source, resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func parseTerraformState(data []byte) ([]terraformResource, error) {
+	var state terraformState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse terraform state: %w", err)
+	}
+	if state.Version != 4 {
+		return nil, fmt.Errorf("unsupported terraform state version %d", state.Version)
+	}
+
+	var resources []terraformResource
+	for _, r := range state.Resources {
+		if r.Mode != "managed" {
+			continue
+		}
+		address := r.Type + "." + r.Name
+		if r.Module != "" {
+			address = r.Module + "." + address
+		}
+
+		for _, inst := range r.Instances {
+			instAddress := address
+			switch key := inst.IndexKey.(type) {
+			case string:
+				instAddress = fmt.Sprintf("%s[%q]", address, key)
+			case float64:
+				instAddress = fmt.Sprintf("%s[%d]", address, int(key))
+			}
+			resources = append(resources, terraformResource{Address: instAddress, Type: r.Type, Attributes: inst.Attributes})
+		}
+	}
+	return resources, nil
+}
+
+// terraformTargets maps the resource types we understand to scan targets
+func terraformTargets(resources []terraformResource) []config.Target {
+	// Listeners reference their load balancer by ARN
+	lbDNS := make(map[string]string)
+	for _, r := range resources {
+		if r.Type == "aws_lb" || r.Type == "aws_alb" {
+			lbDNS[tfString(r.Attributes, "arn")] = tfString(r.Attributes, "dns_name")
+		}
+	}
+
+	var targets []config.Target
+	add := func(r terraformResource, host string, port int) {
+		host = config.NormalizeHost(host)
+		if host == "" || strings.HasPrefix(host, "*.") {
+			return
+		}
+		t := config.Target{
+			Host: host,
+			Labels: map[string]string{
+				"terraform_address": r.Address,
+				"terraform_type":    r.Type,
+			},
+		}
+		if port > 0 {
+			t.Ports = []int{port}
+		}
+		targets = append(targets, t)
+	}
+
+	for _, r := range resources {
+		attrs := r.Attributes
+		switch r.Type {
+		case "aws_route53_record":
+			// name may be relative to the zone; fqdn is always complete
+			if isAddressRecord(tfString(attrs, "type")) {
+				host := tfString(attrs, "fqdn")
+				if host == "" {
+					host = tfString(attrs, "name")
+				}
+				add(r, host, 0)
+			}
+		case "google_dns_record_set":
+			if isAddressRecord(tfString(attrs, "type")) {
+				add(r, tfString(attrs, "name"), 0)
+			}
+		case "cloudflare_record", "cloudflare_dns_record":
+			if isAddressRecord(tfString(attrs, "type")) {
+				host := tfString(attrs, "hostname")
+				if host == "" {
+					host = tfString(attrs, "name")
+				}
+				add(r, host, 0)
+			}
+		case "azurerm_dns_a_record", "azurerm_dns_aaaa_record", "azurerm_dns_cname_record",
+			"azurerm_private_dns_a_record", "azurerm_private_dns_aaaa_record", "azurerm_private_dns_cname_record":
+			add(r, tfString(attrs, "fqdn"), 0)
+		case "aws_lb_listener", "aws_alb_listener":
+			protocol := tfString(attrs, "protocol")
+			if protocol == "HTTPS" || protocol == "TLS" {
+				port, _ := attrs["port"].(float64)
+				add(r, lbDNS[tfString(attrs, "load_balancer_arn")], int(port))
+			}
+		case "aws_acm_certificate":
+			// subject_alternative_names usually repeats domain_name
+			names := []string{tfString(attrs, "domain_name")}
+			if sans, ok := attrs["subject_alternative_names"].([]interface{}); ok {
+				for _, san := range sans {
+					if name, ok := san.(string); ok && !slices.Contains(names, name) {
+						names = append(names, name)
+					}
+				}
+			}
+			for _, name := range names {
+				add(r, name, 0)
+			}
+		case "aws_iam_server_certificate":
+			// Imported certificates only carry the PEM body, so read the names from it
+			if certs, err := scan.ParsePEMCertificates([]byte(tfString(attrs, "certificate_body"))); err == nil {
+				leaf := certs[0]
+				if len(leaf.DNSNames) > 0 {
+					for _, name := range leaf.DNSNames {
+						add(r, name, 0)
+					}
+				} else {
+					add(r, leaf.Subject.CommonName, 0)
+				}
+			}
+		}
+	}
+	return targets
+}
+
+func isAddressRecord(recordType string) bool {
+	switch strings.ToUpper(recordType) {
+	case "A", "AAAA", "CNAME":
+		return true
+	}
+	return false
+}
+
+func tfString(attrs map[string]interface{}, key string) string {
+	s, _ := attrs[key].(string)
+	return s
+}
@@ -0,0 +1,120 @@
+package discovery
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func terraformStateJSON(t *testing.T) []byte {
+	iamPEM := testCertPEM(t, "legacy.example.com", []string{"legacy.example.com"}, time.Now().Add(24*time.Hour))
+	state := map[string]interface{}{
+		"version": 4,
+		"resources": []map[string]interface{}{
+			{"mode": "managed", "type": "aws_route53_record", "name": "www", "instances": []map[string]interface{}{
+				{"attributes": map[string]interface{}{"name": "www.example.com", "type": "CNAME"}},
+			}},
+			{"mode": "managed", "type": "aws_route53_record", "name": "app", "instances": []map[string]interface{}{
+				{"attributes": map[string]interface{}{"name": "app", "fqdn": "app.example.com", "type": "A"}},
+			}},
+			{"mode": "managed", "type": "aws_route53_record", "name": "mx", "instances": []map[string]interface{}{
+				{"attributes": map[string]interface{}{"name": "example.com", "type": "MX"}},
+			}},
+			{"module": "module.edge", "mode": "managed", "type": "cloudflare_record", "name": "api", "instances": []map[string]interface{}{
+				{"index_key": "eu", "attributes": map[string]interface{}{"name": "api-eu", "hostname": "api-eu.example.com", "type": "A"}},
+			}},
+			{"mode": "managed", "type": "azurerm_dns_a_record", "name": "portal", "instances": []map[string]interface{}{
+				{"index_key": 0, "attributes": map[string]interface{}{"fqdn": "portal.example.com."}},
+			}},
+			{"mode": "managed", "type": "aws_lb", "name": "internal", "instances": []map[string]interface{}{
+				{"attributes": map[string]interface{}{"arn": "arn:lb/internal", "dns_name": "internal-1.elb.amazonaws.com"}},
+			}},
+			{"mode": "managed", "type": "aws_lb_listener", "name": "tls", "instances": []map[string]interface{}{
+				{"attributes": map[string]interface{}{"load_balancer_arn": "arn:lb/internal", "protocol": "TLS", "port": 8443}},
+			}},
+			{"mode": "managed", "type": "aws_lb_listener", "name": "http", "instances": []map[string]interface{}{
+				{"attributes": map[string]interface{}{"load_balancer_arn": "arn:lb/internal", "protocol": "HTTP", "port": 80}},
+			}},
+			{"mode": "managed", "type": "aws_acm_certificate", "name": "shop", "instances": []map[string]interface{}{
+				{"attributes": map[string]interface{}{"domain_name": "shop.example.com",
+					"subject_alternative_names": []string{"shop.example.com", "*.shop.example.com", "checkout.example.com"}}},
+			}},
+			{"mode": "managed", "type": "aws_iam_server_certificate", "name": "legacy", "instances": []map[string]interface{}{
+				{"attributes": map[string]interface{}{"certificate_body": string(iamPEM)}},
+			}},
+			{"mode": "data", "type": "aws_route53_record", "name": "ignored", "instances": []map[string]interface{}{
+				{"attributes": map[string]interface{}{"name": "data.example.com", "type": "A"}},
+			}},
+		},
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestFetchTargetsFromTerraformState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "terraform.tfstate")
+	os.WriteFile(path, terraformStateJSON(t), 0o644)
+
+	targets, err := FetchTargetsFromTerraformState(TerraformStateOptions{Sources: []string{path}})
+
+	assert.NoError(t, err)
+	var addresses, hosts []string
+	for _, tgt := range targets {
+		hosts = append(hosts, tgt.Host)
+		addresses = append(addresses, tgt.Labels["terraform_address"])
+	}
+	assert.Equal(t, []string{
+		"www.example.com", "app.example.com", "api-eu.example.com", "portal.example.com", "internal-1.elb.amazonaws.com",
+		"shop.example.com", "checkout.example.com", "legacy.example.com",
+	}, hosts, "route53 fqdn wins over a zone-relative name, ACM SANs get their own targets")
+	assert.Equal(t, []string{
+		"aws_route53_record.www",
+		"aws_route53_record.app",
+		`module.edge.cloudflare_record.api["eu"]`,
+		"azurerm_dns_a_record.portal[0]",
+		"aws_lb_listener.tls",
+		"aws_acm_certificate.shop",
+		"aws_acm_certificate.shop",
+		"aws_iam_server_certificate.legacy",
+	}, addresses)
+	assert.Equal(t, []int{8443}, targets[4].Ports)
+	assert.Equal(t, path, targets[0].Labels["terraform_state"])
+}
+
+func TestFetchTargetsFromTerraformState_HTTP(t *testing.T) {
+	state := terraformStateJSON(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "tf" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write(state)
+	}))
+	defer ts.Close()
+
+	targets, err := FetchTargetsFromTerraformState(TerraformStateOptions{Sources: []string{ts.URL}, Username: "tf", Password: "secret"})
+	assert.NoError(t, err)
+	assert.Len(t, targets, 8)
+
+	_, err = FetchTargetsFromTerraformState(TerraformStateOptions{Sources: []string{ts.URL}})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "status 401")
+}
+
+func TestFetchTargetsFromTerraformState_UnsupportedVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old.tfstate")
+	os.WriteFile(path, []byte(`{"version": 3, "modules": []}`), 0o644)
+
+	_, err := FetchTargetsFromTerraformState(TerraformStateOptions{Sources: []string{path}})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "version 3")
+}
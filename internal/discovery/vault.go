@@ -0,0 +1,223 @@
+package discovery
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/andre/ssl-cert-test/internal/config"
+	"github.com/andre/ssl-cert-test/internal/scan"
+)
+
+// VaultOptions selects the Vault PKI mounts to inventory. Address and Token
+// default to $VAULT_ADDR and $VAULT_TOKEN; when RoleID is set an AppRole login
+// replaces the token.
+type VaultOptions struct {
+	Address      string
+	Token        string
+	RoleID       string
+	SecretID     string
+	AppRoleMount string // Defaults to "approle"
+	Namespace    string // Vault Enterprise namespace
+	Mounts       []string
+}
+
+// vaultResponse is the envelope of every Vault API reply
+type vaultResponse struct {
+	Data struct {
+		Keys           []string `json:"keys"`
+		Certificate    string   `json:"certificate"`
+		RevocationTime int64    `json:"revocation_time"`
+	} `json:"data"`
+	Auth struct {
+		ClientToken string `json:"client_token"`
+	} `json:"auth"`
+	Errors []string `json:"errors"`
+}
+
+type vaultClient struct {
+	address   string
+	token     string
+	namespace string
+	http      *http.Client
+}
+
+// FetchVaultCertificates lists every serial issued by each PKI mount, reads
+// and parses the certificates and returns one row per certificate that has not
+// been revoked, labelled with its mount. A mount that cannot be listed becomes
+// an error row so the other mounts are still reported.
+func FetchVaultCertificates(opts VaultOptions, now time.Time) ([]config.DomainValidity, error) {
+	if opts.Address == "" {
+		opts.Address = os.Getenv("VAULT_ADDR")
+	}
+	if opts.Token == "" {
+		opts.Token = os.Getenv("VAULT_TOKEN")
+	}
+	if opts.Address == "" {
+		return nil, fmt.Errorf("vault address is required")
+	}
+	if len(opts.Mounts) == 0 {
+		return nil, fmt.Errorf("at least one vault pki mount is required")
+	}
+
+	vc := &vaultClient{
+		address:   strings.TrimSuffix(opts.Address, "/"),
+		token:     opts.Token,
+		namespace: opts.Namespace,
+		http:      &http.Client{Timeout: 30 * time.Second},
+	}
+	if opts.RoleID != "" {
+		if err := vc.loginAppRole(opts); err != nil {
+			return nil, err
+		}
+	}
+	if vc.token == "" {
+		return nil, fmt.Errorf("a vault token or approle credentials are required")
+	}
+
+	var results []config.DomainValidity
+	for _, mount := range opts.Mounts {
+		mount = strings.Trim(mount, "/")
+		labels := map[string]string{"vault_mount": mount}
+
+		var list vaultResponse
+		err := vc.do("GET", "/v1/"+mount+"/certs?list=true", nil, &list)
+		if err != nil {
+			results = append(results, discoveryError("vault", mount, labels, err))
+			continue
+		}
+
+		for _, serial := range list.Data.Keys {
+			var cert vaultResponse
+			if err := vc.do("GET", "/v1/"+mount+"/cert/"+url.PathEscape(serial), nil, &cert); err != nil {
+				results = append(results, discoveryError("vault", serial, labels, err))
+				continue
+			}
+			if cert.Data.RevocationTime > 0 {
+				continue
+			}
+
+			certs, err := scan.ParsePEMCertificates([]byte(cert.Data.Certificate))
+			if err != nil {
+				results = append(results, discoveryError("vault", serial, labels, err))
+				continue
+			}
+			row := scan.CertificateResult("vault", "", certs[0], now)
+			row.Labels = labels
+			results = append(results, row)
+		}
+	}
+	return results, nil
+}
+
+// MarkUnservedVaultCerts flags Vault certificates that are about to expire
+// but were not seen on any endpoint scanned in the same run, matching by
+// fingerprint or serial. Handshakes count even when they failed an
+// expectation, and so do the certificates Envoy and HAProxy report loaded.
+// Unserved ones are likely forgotten or need renewing outside the usual path;
+// served ones are labelled with the endpoint instead.
+func MarkUnservedVaultCerts(results []config.DomainValidity, alertDays int) {
+	served := make(map[string]string)
+	for _, r := range results {
+		var endpoint string
+		switch r.Source {
+		case "":
+			endpoint = fmt.Sprintf("%s:%d", r.Domain, r.Port)
+		case "envoy":
+			endpoint = "envoy:" + r.Labels["envoy_admin"]
+		case "haproxy":
+			endpoint = "haproxy:" + r.Labels["haproxy_cert"]
+		default:
+			continue
+		}
+		if r.Fingerprint != "" {
+			served[r.Fingerprint] = endpoint
+		}
+		if r.Serial != "" {
+			served["serial:"+r.Serial] = endpoint
+		}
+	}
+
+	for i := range results {
+		r := &results[i]
+		if r.Source != "vault" || r.Error != "" {
+			continue
+		}
+		endpoint, ok := served[r.Fingerprint]
+		if !ok {
+			endpoint, ok = served["serial:"+r.Serial]
+		}
+		if ok {
+			r.Labels = config.WithLabel(r.Labels, "vault_live_endpoint", endpoint)
+			continue
+		}
+		if r.DaysUntilExpiry >= 0 && r.DaysUntilExpiry <= r.AlertThreshold(alertDays) {
+			r.Error = fmt.Sprintf("expires in %d days and no scanned endpoint serves it", r.DaysUntilExpiry)
+		}
+	}
+}
+
+// loginAppRole exchanges the role and secret IDs for a client token
+func (vc *vaultClient) loginAppRole(opts VaultOptions) error {
+	mount := opts.AppRoleMount
+	if mount == "" {
+		mount = "approle"
+	}
+	body, _ := json.Marshal(map[string]string{"role_id": opts.RoleID, "secret_id": opts.SecretID})
+
+	var login vaultResponse
+	if err := vc.do("POST", "/v1/auth/"+strings.Trim(mount, "/")+"/login", body, &login); err != nil {
+		return fmt.Errorf("vault approle login failed: %w", err)
+	}
+	if login.Auth.ClientToken == "" {
+		return fmt.Errorf("vault approle login returned no token")
+	}
+	vc.token = login.Auth.ClientToken
+	return nil
+}
+
+func (vc *vaultClient) do(method, path string, body []byte, out *vaultResponse) error {
+	req, err := http.NewRequest(method, vc.address+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid vault url: %w", err)
+	}
+	if vc.token != "" {
+		req.Header.Set("X-Vault-Token", vc.token)
+	}
+	if vc.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", vc.namespace)
+	}
+
+	resp, err := vc.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to query vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read vault response: %w", err)
+	}
+	// Vault answers an empty LIST with 404
+	if resp.StatusCode == http.StatusNotFound && strings.Contains(path, "list=true") {
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		var failure vaultResponse
+		json.Unmarshal(data, &failure)
+		if len(failure.Errors) > 0 {
+			return fmt.Errorf("vault returned status %d: %s", resp.StatusCode, strings.Join(failure.Errors, "; "))
+		}
+		return fmt.Errorf("vault returned status %d", resp.StatusCode)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to parse vault response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andre/ssl-cert-test/internal/config"
+	"github.com/andre/ssl-cert-test/internal/scan"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchVaultCertificates(t *testing.T) {
+	now := time.Now()
+	expiring := string(testCertPEM(t, "billing.internal", []string{"billing.internal"}, now.Add(10*24*time.Hour)))
+	longLived := string(testCertPEM(t, "db.internal", []string{"db.internal"}, now.Add(300*24*time.Hour)))
+
+	certs := map[string]map[string]interface{}{
+		"11-aa": {"certificate": expiring, "revocation_time": 0},
+		"22-bb": {"certificate": longLived, "revocation_time": 0},
+		"33-cc": {"certificate": expiring, "revocation_time": 1760000000},
+	}
+
+	var tokens []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/auth/approle/login" {
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			assert.Equal(t, map[string]string{"role_id": "role", "secret_id": "secret"}, body)
+			w.Write([]byte(`{"auth": {"client_token": "s.approle"}}`))
+			return
+		}
+
+		tokens = append(tokens, r.Header.Get("X-Vault-Token"))
+		switch {
+		case r.URL.Path == "/v1/pki_int/certs" && r.URL.Query().Get("list") == "true":
+			w.Write([]byte(`{"data": {"keys": ["11-aa", "22-bb", "33-cc"]}}`))
+		case r.URL.Path == "/v1/pki_empty/certs":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors": []}`))
+		case r.URL.Path == "/v1/pki_denied/certs":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors": ["permission denied"]}`))
+		case len(r.URL.Path) > len("/v1/pki_int/cert/"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": certs[r.URL.Path[len("/v1/pki_int/cert/"):]]})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	results, err := FetchVaultCertificates(VaultOptions{
+		Address:  ts.URL,
+		RoleID:   "role",
+		SecretID: "secret",
+		Mounts:   []string{"pki_int", "/pki_empty/", "pki_denied"},
+	}, now)
+	assert.NoError(t, err)
+
+	for _, token := range tokens {
+		assert.Equal(t, "s.approle", token, "the approle token is used for every request")
+	}
+
+	assert.Len(t, results, 3, "revoked certificates are skipped")
+	assert.Equal(t, "billing.internal", results[0].Domain)
+	assert.Equal(t, "vault", results[0].Source)
+	assert.Equal(t, map[string]string{"vault_mount": "pki_int"}, results[0].Labels)
+	assert.Equal(t, 9, results[0].DaysUntilExpiry)
+	assert.Equal(t, "db.internal", results[1].Domain)
+
+	assert.Equal(t, "pki_denied", results[2].Domain)
+	assert.Contains(t, results[2].Error, "vault returned status 403: permission denied")
+	assert.Equal(t, 999999, results[2].DaysUntilExpiry)
+}
+
+func TestFetchVaultCertificates_Auth(t *testing.T) {
+	t.Setenv("VAULT_TOKEN", "")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"errors": ["invalid role or secret ID"]}`))
+	}))
+	defer ts.Close()
+
+	_, err := FetchVaultCertificates(VaultOptions{Address: ts.URL, Mounts: []string{"pki"}}, time.Now())
+	assert.ErrorContains(t, err, "a vault token or approle credentials are required")
+
+	_, err = FetchVaultCertificates(VaultOptions{Address: ts.URL, RoleID: "role", Mounts: []string{"pki"}}, time.Now())
+	assert.ErrorContains(t, err, "vault approle login failed: vault returned status 400: invalid role or secret ID")
+}
+
+func TestMarkUnservedVaultCerts(t *testing.T) {
+	results := []config.DomainValidity{
+		{Domain: "api.internal", Port: 443, Fingerprint: "f1", Serial: "a1", DaysUntilExpiry: 10},
+		{Domain: "down.internal", Port: 443, Error: "connection refused", DaysUntilExpiry: 999999},
+		{Source: "vault", Domain: "api.internal", Fingerprint: "f1", DaysUntilExpiry: 10},
+		{Source: "vault", Domain: "mq.internal", Serial: "a1", DaysUntilExpiry: 10},
+		{Source: "vault", Domain: "batch.internal", Fingerprint: "f2", DaysUntilExpiry: 10},
+		{Source: "vault", Domain: "later.internal", Fingerprint: "f3", DaysUntilExpiry: 200},
+		{Source: "vault", Domain: "strict.internal", Fingerprint: "f4", DaysUntilExpiry: 40, AlertDays: 45},
+		{Source: "vault", Domain: "expired.internal", Fingerprint: "f5", DaysUntilExpiry: -3},
+		{Source: "acm", Domain: "elsewhere.example.com", Fingerprint: "f6", DaysUntilExpiry: 10},
+	}
+
+	MarkUnservedVaultCerts(results, 30)
+
+	assert.Equal(t, "api.internal:443", results[2].Labels["vault_live_endpoint"])
+	assert.Empty(t, results[2].Error)
+	assert.Equal(t, "api.internal:443", results[3].Labels["vault_live_endpoint"], "serials match too")
+	assert.Equal(t, "expires in 10 days and no scanned endpoint serves it", results[4].Error)
+	assert.Empty(t, results[5].Error, "not expiring yet")
+	assert.NotEmpty(t, results[6].Error, "the row's own threshold applies")
+	assert.Empty(t, results[7].Error, "already expired certificates are history, not drift")
+	assert.Empty(t, results[8].Error, "only vault rows are checked")
+}
+
+func TestMarkUnservedVaultCerts_LiveInventory(t *testing.T) {
+	now := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	notAfter := now.Add(10 * 24 * time.Hour)
+
+	vaultRow := func(cn string) config.DomainValidity {
+		certs, err := scan.ParsePEMCertificates(testCertPEM(t, cn, []string{cn}, notAfter))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return scan.CertificateResult("vault", "", certs[0], now)
+	}
+	edge, mesh, pinned, orphan := vaultRow("edge.internal"), vaultRow("checkout.internal"), vaultRow("pay.internal"), vaultRow("old.internal")
+
+	envoy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"certificates": [{"cert_chain": [{"path": "<inline>", "serial_number": "%s",
+			"subject_alt_names": [{"dns": "checkout.internal"}], "expiration_time": "%s"}]}]}`,
+			strings.ToUpper(mesh.Serial), notAfter.Format(time.RFC3339))
+	}))
+	defer envoy.Close()
+	socket := fakeHAProxy(t, map[string]string{
+		"show ssl cert": "# filename\n/etc/haproxy/certs/edge.pem\n",
+		"show ssl cert /etc/haproxy/certs/edge.pem": "Serial: " + strings.ToUpper(edge.Serial) + "\nnotAfter: " +
+			notAfter.Format("Jan _2 15:04:05 2006 GMT") + "\nSubject Alternative Name: DNS:edge.internal\n",
+	})
+
+	results, err := FetchProxyAdminCertificates(ProxyAdminOptions{EnvoyAdmin: []string{envoy.URL}, HAProxySockets: []string{socket}}, now)
+	assert.NoError(t, err)
+	results = append(results,
+		config.DomainValidity{Domain: "pay.internal", Port: 443, Fingerprint: pinned.Fingerprint, Error: "fingerprint mismatch"},
+		edge, mesh, pinned, orphan)
+
+	MarkUnservedVaultCerts(results, 30)
+
+	assert.Equal(t, "haproxy:/etc/haproxy/certs/edge.pem", results[3].Labels["vault_live_endpoint"])
+	assert.Empty(t, results[3].Error)
+	assert.Equal(t, "envoy:"+envoy.URL, results[4].Labels["vault_live_endpoint"])
+	assert.Empty(t, results[4].Error)
+	assert.Equal(t, "pay.internal:443", results[5].Labels["vault_live_endpoint"], "a failed expectation still proves it is served")
+	assert.Empty(t, results[5].Error)
+	assert.Contains(t, results[6].Error, "no scanned endpoint serves it")
+}
@@ -0,0 +1,671 @@
+package discovery
+
+import (
+	"bufio"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andre/ssl-cert-test/internal/config"
+	"github.com/andre/ssl-cert-test/internal/scan"
+)
+
+// WebServerOptions lists the main nginx, Apache and HAProxy configuration
+// files to read; includes are followed. Vhosts listening on every interface
+// are dialled at Address.
+type WebServerOptions struct {
+	Paths   []string
+	Address string
+}
+
+// webVhost is one TLS virtual host or bind line found in a configuration file
+type webVhost struct {
+	Server    string   // nginx, apache, haproxy
+	Source    string   // file:line of the block
+	Names     []string // Server names; empty means take them from the certificate
+	Listens   []webListen
+	CertFiles []string
+}
+
+type webListen struct {
+	Address string // Empty when listening on every interface
+	Port    int
+}
+
+// maxIncludeDepth stops include loops
+const maxIncludeDepth = 10
+
+// FetchTargetsFromWebServerConfigs parses the configuration files and returns a
+// handshake target per vhost name and listen port, plus one row per referenced
+// certificate file. Targets pin the fingerprints of the files on disk, so a
+// vhost still serving an older certificate (a reload forgotten after renewal)
+// is flagged by the scan.
+func FetchTargetsFromWebServerConfigs(opts WebServerOptions, now time.Time) ([]config.Target, []config.DomainValidity, error) {
+	if len(opts.Paths) == 0 {
+		return nil, nil, fmt.Errorf("at least one web server configuration file is required")
+	}
+
+	var vhosts []webVhost
+	for _, pattern := range opts.Paths {
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid config path %q: %w", pattern, err)
+		}
+		if len(files) == 0 {
+			return nil, nil, fmt.Errorf("no web server configuration found at %s", pattern)
+		}
+		for _, file := range files {
+			found, err := parseWebServerConfig(file)
+			if err != nil {
+				return nil, nil, err
+			}
+			vhosts = append(vhosts, found...)
+		}
+	}
+
+	var targets []config.Target
+	var results []config.DomainValidity
+	seenTarget := make(map[string]bool)
+	seenCert := make(map[string]bool)
+
+	for _, v := range vhosts {
+		labels := map[string]string{"webserver": v.Server, "webserver_config": v.Source}
+
+		var fingerprints []string
+		var certNames []string
+		for _, file := range v.CertFiles {
+			certLabels := config.WithLabel(labels, "webserver_cert", file)
+			certs, err := readCertFile(file)
+			if err != nil {
+				if !seenCert[file] {
+					seenCert[file] = true
+					results = append(results, discoveryError("webserver-file", file, certLabels, err))
+				}
+				continue
+			}
+
+			row := scan.CertificateResult("webserver-file", "", certs[0], now)
+			row.Labels = certLabels
+			fingerprints = append(fingerprints, row.Fingerprint)
+			certNames = append(certNames, row.SANs...)
+			if len(row.SANs) == 0 && row.CommonName != "" {
+				certNames = append(certNames, row.CommonName)
+			}
+
+			if !seenCert[file] {
+				seenCert[file] = true
+				results = append(results, row)
+			}
+		}
+
+		names := v.Names
+		if len(names) == 0 {
+			names = certNames
+		}
+
+		for _, name := range names {
+			name = config.NormalizeHost(name)
+			if name == "" || strings.ContainsAny(name, "*~") {
+				continue
+			}
+			for _, l := range v.Listens {
+				address := l.Address
+				if address == "" {
+					address = opts.Address
+				}
+				key := fmt.Sprintf("%s|%s|%d", name, address, l.Port)
+				if seenTarget[key] {
+					continue
+				}
+				seenTarget[key] = true
+
+				t := config.Target{
+					Host:                name,
+					Address:             address,
+					Ports:               []int{l.Port},
+					Labels:              labels,
+					ExpectedFingerprint: strings.Join(fingerprints, ","),
+				}
+				if len(v.CertFiles) > 0 {
+					t.Labels = config.WithLabel(labels, "webserver_cert", strings.Join(v.CertFiles, ","))
+				}
+				targets = append(targets, t)
+			}
+		}
+	}
+	return targets, results, nil
+}
+
+// parseWebServerConfig detects the server by file name, then by content
+func parseWebServerConfig(path string) ([]webVhost, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read web server config: %w", err)
+	}
+	base := strings.ToLower(filepath.Base(path))
+	text := string(data)
+
+	switch {
+	case strings.Contains(base, "haproxy"):
+		return parseHAProxyConfig(path)
+	case strings.Contains(base, "apache") || strings.Contains(base, "httpd"):
+		return parseApacheConfig(path)
+	case strings.Contains(base, "nginx"):
+		return parseNginxConfig(path)
+	case strings.Contains(text, "<VirtualHost"):
+		return parseApacheConfig(path)
+	case strings.Contains(text, "\nfrontend ") || strings.Contains(text, "\nlisten "):
+		return parseHAProxyConfig(path)
+	default:
+		return parseNginxConfig(path)
+	}
+}
+
+// -- nginx --
+
+type ngxDirective struct {
+	Name  string
+	Args  []string
+	Block []ngxDirective
+	File  string
+	Line  int
+}
+
+func parseNginxConfig(path string) ([]webVhost, error) {
+	root, err := readNginxFile(path, filepath.Dir(path), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// Relative certificate paths are resolved against the configuration directory
+	confDir := filepath.Dir(path)
+	resolve := func(cert string) string {
+		if filepath.IsAbs(cert) {
+			return cert
+		}
+		return filepath.Join(confDir, cert)
+	}
+
+	var vhosts []webVhost
+	var walk func(dirs []ngxDirective, inheritedCerts []string)
+	walk = func(dirs []ngxDirective, inheritedCerts []string) {
+		// ssl_certificate is inherited from the enclosing http block; paths
+		// are resolved once the server that uses them is known
+		certs := inheritedCerts
+		var own []string
+		for _, d := range dirs {
+			if d.Name == "ssl_certificate" && len(d.Args) > 0 {
+				own = append(own, d.Args[0])
+			}
+		}
+		if len(own) > 0 {
+			certs = own
+		}
+
+		for _, d := range dirs {
+			switch {
+			case d.Name == "server" && d.Block != nil:
+				if v, ok := nginxServer(d, certs); ok {
+					for i, cert := range v.CertFiles {
+						v.CertFiles[i] = resolve(cert)
+					}
+					vhosts = append(vhosts, v)
+				}
+			case d.Block != nil:
+				walk(d.Block, certs)
+			}
+		}
+	}
+	walk(root, nil)
+	return vhosts, nil
+}
+
+// nginxServer turns a server block into a vhost when it listens with TLS
+func nginxServer(server ngxDirective, inheritedCerts []string) (webVhost, bool) {
+	v := webVhost{Server: "nginx", Source: fmt.Sprintf("%s:%d", server.File, server.Line)}
+	sslOn := false
+	var listens []ngxDirective
+	var own []string
+
+	for _, d := range server.Block {
+		switch d.Name {
+		case "listen":
+			listens = append(listens, d)
+		case "server_name":
+			for _, name := range d.Args {
+				if name == "_" || name == "" {
+					continue
+				}
+				// ".example.com" covers the bare domain and its subdomains
+				v.Names = append(v.Names, strings.TrimPrefix(name, "."))
+			}
+		case "ssl_certificate":
+			if len(d.Args) > 0 {
+				own = append(own, d.Args[0])
+			}
+		case "ssl":
+			sslOn = len(d.Args) > 0 && d.Args[0] == "on"
+		}
+	}
+
+	for _, l := range listens {
+		if len(l.Args) == 0 || strings.HasPrefix(l.Args[0], "unix:") {
+			continue
+		}
+		isSSL := sslOn
+		for _, arg := range l.Args[1:] {
+			if arg == "ssl" {
+				isSSL = true
+			}
+		}
+		if !isSSL {
+			continue
+		}
+		if listen, ok := parseListen(l.Args[0], 80); ok {
+			v.Listens = append(v.Listens, listen)
+		}
+	}
+
+	v.CertFiles = own
+	if len(own) == 0 {
+		v.CertFiles = slices.Clone(inheritedCerts)
+	}
+	return v, len(v.Listens) > 0
+}
+
+// readNginxFile tokenizes one file into directives, splicing in includes
+func readNginxFile(path, confDir string, depth int) ([]ngxDirective, error) {
+	if depth > maxIncludeDepth {
+		return nil, fmt.Errorf("%s: includes nested too deeply", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read web server config: %w", err)
+	}
+
+	tokens := tokenizeNginx(string(data))
+	pos := 0
+	var parse func() ([]ngxDirective, error)
+	parse = func() ([]ngxDirective, error) {
+		var dirs []ngxDirective
+		var current *ngxDirective
+		for pos < len(tokens) {
+			tok := tokens[pos]
+			pos++
+			switch {
+			case tok.text == "}" && !tok.quoted:
+				return dirs, nil
+			case tok.text == ";" && !tok.quoted:
+				if current != nil {
+					if current.Name == "include" && len(current.Args) > 0 {
+						included, err := nginxInclude(current.Args[0], confDir, depth)
+						if err != nil {
+							return nil, err
+						}
+						dirs = append(dirs, included...)
+					} else {
+						dirs = append(dirs, *current)
+					}
+				}
+				current = nil
+			case tok.text == "{" && !tok.quoted:
+				block, err := parse()
+				if err != nil {
+					return nil, err
+				}
+				if current != nil {
+					current.Block = block
+					dirs = append(dirs, *current)
+				}
+				current = nil
+			default:
+				if current == nil {
+					current = &ngxDirective{Name: tok.text, File: path, Line: tok.line}
+				} else {
+					current.Args = append(current.Args, tok.text)
+				}
+			}
+		}
+		return dirs, nil
+	}
+	return parse()
+}
+
+func nginxInclude(pattern, confDir string, depth int) ([]ngxDirective, error) {
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(confDir, pattern)
+	}
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid include %q: %w", pattern, err)
+	}
+	var dirs []ngxDirective
+	for _, f := range files {
+		included, err := readNginxFile(f, confDir, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, included...)
+	}
+	return dirs, nil
+}
+
+type ngxToken struct {
+	text   string
+	quoted bool
+	line   int
+}
+
+func tokenizeNginx(s string) []ngxToken {
+	var tokens []ngxToken
+	line := 1
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\n':
+			line++
+		case c == ' ' || c == '\t' || c == '\r':
+		case c == '#':
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+			i--
+		case c == ';' || c == '{' || c == '}':
+			tokens = append(tokens, ngxToken{text: string(c), line: line})
+		case c == '"' || c == '\'':
+			start := i + 1
+			for i++; i < len(s) && s[i] != c; i++ {
+				if s[i] == '\\' {
+					i++
+				}
+			}
+			end := min(i, len(s))
+			tokens = append(tokens, ngxToken{text: s[start:end], quoted: true, line: line})
+		default:
+			start := i
+			for i < len(s) && !strings.ContainsRune(" \t\r\n;{}", rune(s[i])) {
+				i++
+			}
+			tokens = append(tokens, ngxToken{text: s[start:i], line: line})
+			i--
+		}
+	}
+	return tokens
+}
+
+// -- Apache --
+
+func parseApacheConfig(path string) ([]webVhost, error) {
+	p := &apacheParser{serverRoot: filepath.Dir(path)}
+	if err := p.readFile(path, 0); err != nil {
+		return nil, err
+	}
+	return p.vhosts, nil
+}
+
+type apacheParser struct {
+	serverRoot string
+	vhosts     []webVhost
+	current    *webVhost
+	sslEngine  bool
+}
+
+func (p *apacheParser) readFile(path string, depth int) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("%s: includes nested too deeply", path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read web server config: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	var pending string
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		// Backslash continues a directive on the next line
+		if strings.HasSuffix(line, "\\") {
+			pending += strings.TrimSuffix(line, "\\") + " "
+			continue
+		}
+		line, pending = strings.TrimSpace(pending+line), ""
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := splitApacheArgs(line)
+		name := strings.ToLower(fields[0])
+		args := fields[1:]
+
+		switch {
+		case name == "serverroot" && len(args) > 0:
+			p.serverRoot = args[0]
+		case (name == "include" || name == "includeoptional") && len(args) > 0:
+			pattern := args[0]
+			if !filepath.IsAbs(pattern) {
+				pattern = filepath.Join(p.serverRoot, pattern)
+			}
+			files, err := filepath.Glob(pattern)
+			if err != nil {
+				return fmt.Errorf("invalid include %q: %w", pattern, err)
+			}
+			for _, f := range files {
+				if err := p.readFile(f, depth+1); err != nil {
+					return err
+				}
+			}
+		case strings.HasPrefix(name, "<virtualhost"):
+			v := webVhost{Server: "apache", Source: fmt.Sprintf("%s:%d", path, lineNo)}
+			for _, addr := range args {
+				if listen, ok := parseListen(strings.TrimSuffix(addr, ">"), 443); ok {
+					v.Listens = append(v.Listens, listen)
+				}
+			}
+			p.current, p.sslEngine = &v, false
+		case name == "</virtualhost>":
+			if p.current != nil && p.sslEngine && len(p.current.Listens) > 0 {
+				p.vhosts = append(p.vhosts, *p.current)
+			}
+			p.current = nil
+		case p.current == nil:
+		case name == "servername" && len(args) > 0:
+			// ServerName may carry a scheme and port, e.g. https://www.example.com:443
+			host := strings.TrimPrefix(strings.TrimPrefix(args[0], "https://"), "http://")
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			p.current.Names = append([]string{host}, p.current.Names...)
+		case name == "serveralias":
+			p.current.Names = append(p.current.Names, args...)
+		case name == "sslengine" && len(args) > 0:
+			p.sslEngine = strings.EqualFold(args[0], "on")
+		case name == "sslcertificatefile" && len(args) > 0:
+			cert := args[0]
+			if !filepath.IsAbs(cert) {
+				cert = filepath.Join(p.serverRoot, cert)
+			}
+			p.current.CertFiles = append(p.current.CertFiles, cert)
+		}
+	}
+	return scanner.Err()
+}
+
+// splitApacheArgs splits on whitespace, keeping double-quoted arguments whole
+func splitApacheArgs(line string) []string {
+	var args []string
+	var b strings.Builder
+	inQuote := false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+		case (r == ' ' || r == '\t') && !inQuote:
+			if b.Len() > 0 {
+				args = append(args, b.String())
+				b.Reset()
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() > 0 {
+		args = append(args, b.String())
+	}
+	return args
+}
+
+// -- HAProxy --
+
+func parseHAProxyConfig(path string) ([]webVhost, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read web server config: %w", err)
+	}
+	defer file.Close()
+
+	var vhosts []webVhost
+	crtBase := ""
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "crt-base":
+			if len(fields) > 1 {
+				crtBase = fields[1]
+			}
+		case "bind":
+			if len(fields) < 2 {
+				continue
+			}
+			v := webVhost{Server: "haproxy", Source: fmt.Sprintf("%s:%d", path, lineNo)}
+			ssl := false
+			for i := 2; i < len(fields); i++ {
+				switch fields[i] {
+				case "ssl":
+					ssl = true
+				case "crt", "crt-list":
+					if i+1 >= len(fields) {
+						continue
+					}
+					i++
+					files, err := haproxyCerts(fields[i-1], crtBase, haproxyPath(crtBase, fields[i]))
+					if err != nil {
+						return nil, err
+					}
+					v.CertFiles = append(v.CertFiles, files...)
+				}
+			}
+			if !ssl {
+				continue
+			}
+			// One bind line may list several comma-separated addresses
+			for _, addr := range strings.Split(fields[1], ",") {
+				if listen, ok := parseListen(addr, 443); ok {
+					v.Listens = append(v.Listens, listen)
+				}
+			}
+			if len(v.Listens) > 0 {
+				vhosts = append(vhosts, v)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read web server config: %w", err)
+	}
+	return vhosts, nil
+}
+
+func haproxyPath(base, p string) string {
+	if base != "" && !filepath.IsAbs(p) {
+		return filepath.Join(base, p)
+	}
+	return p
+}
+
+// haproxyCerts expands a crt argument, which may name a directory of PEM
+// files, or a crt-list file whose lines start with a certificate path
+func haproxyCerts(kind, crtBase, p string) ([]string, error) {
+	if kind == "crt-list" {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read crt-list: %w", err)
+		}
+		var files []string
+		for _, line := range strings.Split(string(data), "\n") {
+			line, _, _ = strings.Cut(line, "#")
+			if fields := strings.Fields(line); len(fields) > 0 {
+				files = append(files, haproxyPath(crtBase, fields[0]))
+			}
+		}
+		return files, nil
+	}
+
+	info, err := os.Stat(p)
+	if err != nil || !info.IsDir() {
+		return []string{p}, nil // Missing files are reported when read
+	}
+	entries, err := os.ReadDir(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate directory: %w", err)
+	}
+	var files []string
+	for _, e := range entries {
+		// HAProxy loads every file except its companion .key/.ocsp/.issuer/.sctl files
+		switch filepath.Ext(e.Name()) {
+		case ".key", ".ocsp", ".issuer", ".sctl":
+			continue
+		}
+		if !e.IsDir() {
+			files = append(files, filepath.Join(p, e.Name()))
+		}
+	}
+	return files, nil
+}
+
+// -- shared --
+
+// parseListen reads "port", "addr:port", "[v6]:port" or "*:port"; wildcard
+// addresses come back empty
+func parseListen(s string, defaultPort int) (webListen, bool) {
+	host, portStr, err := net.SplitHostPort(s)
+	if err != nil {
+		// A bare port or a bare address
+		if port, err := strconv.Atoi(s); err == nil {
+			return webListen{Port: port}, true
+		}
+		host, portStr = s, strconv.Itoa(defaultPort)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 {
+		return webListen{}, false
+	}
+	switch host {
+	case "", "*", "0.0.0.0", "::", "_default_":
+		host = ""
+	default:
+		if net.ParseIP(host) == nil && host != "localhost" {
+			return webListen{}, false
+		}
+	}
+	return webListen{Address: host, Port: port}, true
+}
+
+func readCertFile(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate file: %w", err)
+	}
+	return scan.ParsePEMCertificates(data)
+}
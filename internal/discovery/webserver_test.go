@@ -0,0 +1,239 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andre/ssl-cert-test/internal/config"
+	"github.com/andre/ssl-cert-test/internal/scan"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func fingerprintOf(t *testing.T, pemData string) string {
+	t.Helper()
+	certs, err := scan.ParsePEMCertificates([]byte(pemData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return scan.CertificateResult("", "", certs[0], time.Now()).Fingerprint
+}
+
+func TestFetchTargetsFromWebServerConfigs_Nginx(t *testing.T) {
+	now := time.Now()
+	dir := t.TempDir()
+	shared := string(testCertPEM(t, "shared.example.com", []string{"shared.example.com"}, now.Add(40*24*time.Hour)))
+	shop := string(testCertPEM(t, "shop.example.com", []string{"shop.example.com", "www.shop.example.com"}, now.Add(5*24*time.Hour)))
+
+	writeFiles(t, dir, map[string]string{
+		"nginx.conf": `
+events {}
+http {
+    ssl_certificate certs/shared.pem; # inherited by servers without their own
+    include sites-enabled/*.conf;
+
+    server {
+        listen 80;
+        server_name plain.example.com;
+    }
+}
+`,
+		"sites-enabled/shop.conf": `
+server {
+    listen 10.0.0.5:8443 ssl http2;
+    listen [::]:443 ssl;
+    server_name shop.example.com "www.shop.example.com" ~^regex\.example\.com$ *.shop.example.com;
+    ssl_certificate /missing/shop.pem;
+    ssl_certificate ` + filepath.Join(dir, "certs/shop.pem") + `;
+}
+`,
+		"sites-enabled/zz-default.conf": `
+server {
+    listen 443 ssl default_server;
+    server_name _;
+}
+`,
+		"certs/shared.pem": shared,
+		"certs/shop.pem":   shop,
+	})
+
+	targets, results, err := FetchTargetsFromWebServerConfigs(WebServerOptions{
+		Paths:   []string{filepath.Join(dir, "nginx.conf")},
+		Address: "127.0.0.1",
+	}, now)
+	assert.NoError(t, err)
+
+	shopPin := fingerprintOf(t, shop)
+	assert.Equal(t, []config.Target{
+		{Host: "shop.example.com", Address: "10.0.0.5", Ports: []int{8443}, ExpectedFingerprint: shopPin},
+		{Host: "shop.example.com", Address: "127.0.0.1", Ports: []int{443}, ExpectedFingerprint: shopPin},
+		{Host: "www.shop.example.com", Address: "10.0.0.5", Ports: []int{8443}, ExpectedFingerprint: shopPin},
+		{Host: "www.shop.example.com", Address: "127.0.0.1", Ports: []int{443}, ExpectedFingerprint: shopPin},
+		{Host: "shared.example.com", Address: "127.0.0.1", Ports: []int{443}, ExpectedFingerprint: fingerprintOf(t, shared)},
+	}, stripLabels(targets), "regex and wildcard names are skipped, a nameless server takes the cert's names")
+
+	assert.Equal(t, "nginx", targets[0].Labels["webserver"])
+	assert.Equal(t, filepath.Join(dir, "sites-enabled/shop.conf")+":2", targets[0].Labels["webserver_config"])
+	assert.Equal(t, "/missing/shop.pem,"+filepath.Join(dir, "certs/shop.pem"), targets[0].Labels["webserver_cert"])
+
+	assert.Len(t, results, 3)
+	assert.Equal(t, "/missing/shop.pem", results[0].Domain)
+	assert.Contains(t, results[0].Error, "failed to read certificate file")
+	assert.Equal(t, "shop.example.com", results[1].Domain)
+	assert.Equal(t, "webserver-file", results[1].Source)
+	assert.Equal(t, 4, results[1].DaysUntilExpiry)
+	assert.Equal(t, filepath.Join(dir, "certs/shared.pem"), results[2].Labels["webserver_cert"], "relative paths resolve against the config dir")
+}
+
+func TestFetchTargetsFromWebServerConfigs_Apache(t *testing.T) {
+	now := time.Now()
+	dir := t.TempDir()
+	cert := string(testCertPEM(t, "www.example.com", []string{"www.example.com"}, now.Add(60*24*time.Hour)))
+
+	writeFiles(t, dir, map[string]string{
+		"httpd.conf": `
+ServerRoot "` + dir + `"
+Listen 443
+IncludeOptional sites/*.conf
+IncludeOptional missing/*.conf
+`,
+		"sites/www.conf": `
+<VirtualHost *:80>
+    ServerName www.example.com
+</VirtualHost>
+
+<VirtualHost 192.0.2.10:443 *:8443>
+    ServerName https://www.example.com:443
+    ServerAlias example.com \
+        alias.example.com
+    SSLEngine on
+    SSLCertificateFile "certs/www.pem"
+</VirtualHost>
+
+<VirtualHost *:9443>
+    ServerName off.example.com
+    SSLEngine off
+</VirtualHost>
+`,
+		"certs/www.pem": cert,
+	})
+
+	targets, results, err := FetchTargetsFromWebServerConfigs(WebServerOptions{
+		Paths:   []string{filepath.Join(dir, "httpd.conf")},
+		Address: "10.1.1.1",
+	}, now)
+	assert.NoError(t, err)
+
+	var got []string
+	for _, t := range targets {
+		got = append(got, t.Host+"@"+t.Address)
+	}
+	assert.Equal(t, []string{
+		"www.example.com@192.0.2.10", "www.example.com@10.1.1.1",
+		"example.com@192.0.2.10", "example.com@10.1.1.1",
+		"alias.example.com@192.0.2.10", "alias.example.com@10.1.1.1",
+	}, got)
+	assert.Equal(t, []int{8443}, targets[1].Ports)
+	assert.Equal(t, "apache", targets[0].Labels["webserver"])
+	assert.Equal(t, fingerprintOf(t, cert), targets[0].ExpectedFingerprint)
+	assert.Len(t, results, 1)
+	assert.Equal(t, filepath.Join(dir, "certs/www.pem"), results[0].Labels["webserver_cert"])
+}
+
+func TestFetchTargetsFromWebServerConfigs_HAProxy(t *testing.T) {
+	now := time.Now()
+	dir := t.TempDir()
+	api := string(testCertPEM(t, "api.example.com", []string{"api.example.com", "*.api.example.com"}, now.Add(20*24*time.Hour)))
+	admin := string(testCertPEM(t, "admin.example.com", []string{"admin.example.com"}, now.Add(20*24*time.Hour)))
+
+	writeFiles(t, dir, map[string]string{
+		"haproxy.cfg": `
+global
+    crt-base ` + dir + `/certs
+
+frontend https
+    bind :443,192.0.2.20:8443 ssl crt bundle/ alpn h2
+    bind :80
+
+listen admin
+    bind 127.0.0.2:9443 ssl crt-list ` + dir + `/admin.list
+`,
+		"admin.list":               "admin.pem [alpn h2] admin.example.com\n# comment\n",
+		"certs/bundle/api.pem":     api,
+		"certs/bundle/api.pem.key": "not a certificate",
+		"certs/admin.pem":          admin,
+	})
+
+	targets, results, err := FetchTargetsFromWebServerConfigs(WebServerOptions{
+		Paths:   []string{filepath.Join(dir, "haproxy.cfg")},
+		Address: "127.0.0.1",
+	}, now)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []config.Target{
+		{Host: "api.example.com", Address: "127.0.0.1", Ports: []int{443}, ExpectedFingerprint: fingerprintOf(t, api)},
+		{Host: "api.example.com", Address: "192.0.2.20", Ports: []int{8443}, ExpectedFingerprint: fingerprintOf(t, api)},
+		{Host: "admin.example.com", Address: "127.0.0.2", Ports: []int{9443}, ExpectedFingerprint: fingerprintOf(t, admin)},
+	}, stripLabels(targets), "host names come from the certificates, .key files are skipped")
+	assert.Equal(t, "haproxy", targets[0].Labels["webserver"])
+	assert.Len(t, results, 2)
+}
+
+func TestFetchTargetsFromWebServerConfigs_RelativeConfigPath(t *testing.T) {
+	now := time.Now()
+	dir := t.TempDir()
+	shared := string(testCertPEM(t, "shared.example.com", []string{"shared.example.com"}, now.Add(40*24*time.Hour)))
+	writeFiles(t, dir, map[string]string{
+		"etc/nginx/nginx.conf": `
+http {
+    ssl_certificate certs/shared.pem;
+    server {
+        listen 443 ssl;
+        server_name shared.example.com;
+    }
+}
+`,
+		"etc/nginx/certs/shared.pem": shared,
+	})
+	t.Chdir(dir)
+
+	targets, results, err := FetchTargetsFromWebServerConfigs(WebServerOptions{Paths: []string{"etc/nginx/nginx.conf"}}, now)
+	assert.NoError(t, err)
+
+	assert.Len(t, targets, 1)
+	assert.Equal(t, "etc/nginx/certs/shared.pem", targets[0].Labels["webserver_cert"], "inherited paths are resolved once")
+	assert.Equal(t, fingerprintOf(t, shared), targets[0].ExpectedFingerprint)
+	assert.Len(t, results, 1)
+	assert.Empty(t, results[0].Error)
+}
+
+func TestFetchTargetsFromWebServerConfigs_Errors(t *testing.T) {
+	_, _, err := FetchTargetsFromWebServerConfigs(WebServerOptions{}, time.Now())
+	assert.Error(t, err)
+
+	_, _, err = FetchTargetsFromWebServerConfigs(WebServerOptions{Paths: []string{filepath.Join(t.TempDir(), "nginx.conf")}}, time.Now())
+	assert.ErrorContains(t, err, "no web server configuration found")
+}
+
+func stripLabels(targets []config.Target) []config.Target {
+	out := make([]config.Target, len(targets))
+	for i, t := range targets {
+		t.Labels = nil
+		out[i] = t
+	}
+	return out
+}
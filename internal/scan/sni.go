@@ -0,0 +1,64 @@
+package scan
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/andre/ssl-cert-test/internal/config"
+)
+
+// LookupAddr resolves the PTR names of an IP; swapped out in tests
+var LookupAddr = net.DefaultResolver.LookupAddr
+
+// serverName is a name to re-handshake with and where it was found
+type serverName struct {
+	Name   string
+	Source string // ptr, san or "ptr,san"
+}
+
+// lookupPTR returns the reverse DNS names of ip, or none if the lookup fails
+func lookupPTR(ctx context.Context, ip string, timeout time.Duration) []string {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	names, err := LookupAddr(ctx, ip)
+	if err != nil {
+		slog.Debug("ptr lookup failed", "address", ip, "error", err)
+		return nil
+	}
+	return names
+}
+
+// discoverServerNames merges the PTR names and the default certificate's SANs
+// into the distinct hostnames worth an SNI handshake. Wildcards and IP literals
+// cannot be sent as SNI and are skipped.
+func discoverServerNames(ptrNames, sans []string) []serverName {
+	var names []serverName
+	index := make(map[string]int)
+
+	add := func(name, source string) {
+		name = config.NormalizeHost(name)
+		if name == "" || strings.Contains(name, "*") || net.ParseIP(name) != nil {
+			return
+		}
+		if i, ok := index[name]; ok {
+			if names[i].Source != source {
+				names[i].Source = "ptr,san"
+			}
+			return
+		}
+		index[name] = len(names)
+		names = append(names, serverName{Name: name, Source: source})
+	}
+
+	for _, name := range ptrNames {
+		add(name, "ptr")
+	}
+	for _, name := range sans {
+		add(name, "san")
+	}
+	return names
+}
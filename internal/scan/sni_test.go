@@ -0,0 +1,131 @@
+package scan
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andre/ssl-cert-test/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func selfSigned(t *testing.T, cn string, dnsNames ...string) tls.Certificate {
+	t.Helper()
+	tmpl, key := createCertTemplate(false, cn, nil)
+	tmpl.DNSNames = dnsNames
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestDiscoverServerNames(t *testing.T) {
+	got := discoverServerNames(
+		[]string{"WWW.example.com.", "host-10-0-0-1.isp.example."},
+		[]string{"www.example.com", "*.example.com", "10.0.0.1", "shop.example.com"},
+	)
+	assert.Equal(t, []serverName{
+		{Name: "www.example.com", Source: "ptr,san"},
+		{Name: "host-10-0-0-1.isp.example", Source: "ptr"},
+		{Name: "shop.example.com", Source: "san"},
+	}, got)
+}
+
+func TestProcessTargets_DiscoverSNI(t *testing.T) {
+	defaultCert := selfSigned(t, "default", "www.example.com", "*.example.com")
+	apiCert := selfSigned(t, "api", "api.example.com")
+
+	var gotSNI []string
+	var mu sync.Mutex
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.TLS = &tls.Config{
+		// Served when the client sends no SNI
+		Certificates: []tls.Certificate{defaultCert},
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			mu.Lock()
+			gotSNI = append(gotSNI, hello.ServerName)
+			mu.Unlock()
+			return nil, nil
+		},
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if hello.ServerName == "api.example.com" {
+				return &apiCert, nil
+			}
+			return &defaultCert, nil
+		},
+	}
+	ts.StartTLS()
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	origLookup := LookupAddr
+	defer func() { LookupAddr = origLookup }()
+	LookupAddr = func(ctx context.Context, addr string) ([]string, error) {
+		assert.Equal(t, u.Hostname(), addr)
+		return []string{"api.example.com."}, nil
+	}
+
+	results := make(chan config.DomainValidity, 10)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	ProcessTargets(context.Background(), []config.Target{
+		{Host: u.Hostname(), DiscoverSNI: true, Labels: map[string]string{"zone": "lab"}},
+	}, []int{port}, 5*time.Second, time.Now(), results, &wg)
+	close(results)
+
+	var got []config.DomainValidity
+	for r := range results {
+		got = append(got, r)
+	}
+	assert.Equal(t, []string{"", "api.example.com", "www.example.com"}, gotSNI, "IP literals are never sent as SNI")
+	assert.Len(t, got, 2, "names served the default certificate again get no row of their own")
+
+	assert.Equal(t, u.Hostname(), got[0].Domain)
+	assert.Equal(t, u.Hostname(), got[0].IPAddress, "the default certificate row carries the address like the vhost rows")
+	assert.Equal(t, "default", got[0].CommonName)
+	assert.Equal(t, "www.example.com", got[0].Labels["sni_names"])
+	assert.Equal(t, "lab", got[0].Labels["zone"])
+
+	assert.Equal(t, "api.example.com", got[1].Domain)
+	assert.Equal(t, u.Hostname(), got[1].IPAddress)
+	assert.Equal(t, "api", got[1].CommonName)
+	assert.Equal(t, "ptr", got[1].Labels["sni_source"])
+	assert.Equal(t, "lab", got[1].Labels["zone"])
+	assert.NotContains(t, got[1].ChainStatus, "Hostname Mismatch")
+	assert.Empty(t, got[0].Labels["sni_source"], "the target's own labels are not modified")
+}
+
+func TestProcessTargets_DiscoverSNISkipsHostnames(t *testing.T) {
+	origLookup := LookupAddr
+	defer func() { LookupAddr = origLookup }()
+	LookupAddr = func(ctx context.Context, addr string) ([]string, error) {
+		t.Errorf("unexpected PTR lookup for %s", addr)
+		return nil, nil
+	}
+
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	results := make(chan config.DomainValidity, 10)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	ProcessTargets(context.Background(), []config.Target{
+		{Host: "localhost", DiscoverSNI: true},
+	}, []int{port}, time.Second, time.Now(), results, &wg)
+	close(results)
+
+	assert.Len(t, results, 1)
+}
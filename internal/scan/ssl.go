@@ -2,12 +2,18 @@ package scan
 
 import (
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -27,6 +33,7 @@ type CertDetails struct {
 	Issuer        string
 	SignatureAlgo string
 	SANs          []string
+	Fingerprint   string // SHA-256 of the leaf, lowercase hex
 }
 
 func checkFIPSCompliance(version uint16, cipher uint16) bool {
@@ -72,8 +79,14 @@ func tlsVersionToString(ver uint16) string {
 
 // GetSSLValidity now takes a Context for timeout/cancellation
 func GetSSLValidity(ctx context.Context, domain string, port int) (CertDetails, error) {
+	return getSSLValidity(ctx, domain, domain, port, "")
+}
+
+// getSSLValidity connects to host but presents (and verifies against) domain as the server name.
+// A non-empty protocol (e.g. "smtp") negotiates STARTTLS before the handshake.
+func getSSLValidity(ctx context.Context, host, domain string, port int, protocol string) (CertDetails, error) {
 	var details CertDetails
-	address := fmt.Sprintf("%s:%d", domain, port)
+	address := net.JoinHostPort(host, strconv.Itoa(port))
 
 	// Use Dialer with Context
 	dialer := &net.Dialer{}
@@ -85,6 +98,15 @@ func GetSSLValidity(ctx context.Context, domain string, port int) (CertDetails,
 	}
 	defer rawConn.Close()
 
+	if protocol != "" {
+		if deadline, ok := ctx.Deadline(); ok {
+			rawConn.SetDeadline(deadline)
+		}
+		if err := startTLS(rawConn, protocol); err != nil {
+			return details, fmt.Errorf("starttls failed: %v", err)
+		}
+	}
+
 	// 2. Upgrade to TLS
 	conn := tls.Client(rawConn, &tls.Config{
 		InsecureSkipVerify: true,
@@ -108,21 +130,7 @@ func GetSSLValidity(ctx context.Context, domain string, port int) (CertDetails,
 	}
 
 	leaf := certs[0]
-	details.NotBefore = leaf.NotBefore
-	details.NotAfter = leaf.NotAfter
-	details.CommonName = leaf.Subject.CommonName
-	details.Serial = leaf.SerialNumber.Text(16)
-	details.SANs = leaf.DNSNames
-	details.SignatureAlgo = leaf.SignatureAlgorithm.String()
-
-	details.Issuer = leaf.Issuer.CommonName
-	if details.Issuer == "" {
-		if len(leaf.Issuer.Organization) > 0 {
-			details.Issuer = leaf.Issuer.Organization[0]
-		} else {
-			details.Issuer = "Unknown"
-		}
-	}
+	fillCertDetails(&details, leaf)
 
 	// 5. Chain Validation
 	intermediates := x509.NewCertPool()
@@ -152,48 +160,214 @@ func GetSSLValidity(ctx context.Context, domain string, port int) (CertDetails,
 	return details, nil
 }
 
-// ProcessDomains now accepts a parent Context and uses slog
-func ProcessDomains(ctx context.Context, domains []string, ports []int, timeout time.Duration, now time.Time, resultsChan chan<- config.DomainValidity, wg *sync.WaitGroup) {
+// fillCertDetails copies the leaf certificate fields into details
+func fillCertDetails(details *CertDetails, leaf *x509.Certificate) {
+	details.NotBefore = leaf.NotBefore
+	details.NotAfter = leaf.NotAfter
+	details.CommonName = leaf.Subject.CommonName
+	details.Serial = leaf.SerialNumber.Text(16)
+	details.SANs = leaf.DNSNames
+	details.SignatureAlgo = leaf.SignatureAlgorithm.String()
+	sum := sha256.Sum256(leaf.Raw)
+	details.Fingerprint = hex.EncodeToString(sum[:])
+
+	details.Issuer = leaf.Issuer.CommonName
+	if details.Issuer == "" {
+		if len(leaf.Issuer.Organization) > 0 {
+			details.Issuer = leaf.Issuer.Organization[0]
+		} else {
+			details.Issuer = "Unknown"
+		}
+	}
+}
+
+// DaysUntil returns the whole days left before notAfter, the same way scan results count them
+func DaysUntil(notAfter, now time.Time) int {
+	return int(notAfter.Sub(now).Hours() / 24)
+}
+
+// CertificateResult builds a result row from a certificate obtained without a
+// handshake (API inventory, files on disk, ...). domain defaults to the CN.
+func CertificateResult(source, domain string, cert *x509.Certificate, now time.Time) config.DomainValidity {
+	var details CertDetails
+	fillCertDetails(&details, cert)
+
+	if domain == "" {
+		domain = details.CommonName
+		if domain == "" && len(details.SANs) > 0 {
+			domain = details.SANs[0]
+		}
+	}
+
+	return config.DomainValidity{
+		Domain:          domain,
+		Serial:          details.Serial,
+		Issuer:          details.Issuer,
+		SignatureAlgo:   details.SignatureAlgo,
+		SANs:            details.SANs,
+		NotBefore:       details.NotBefore,
+		NotAfter:        details.NotAfter,
+		DaysUntilExpiry: DaysUntil(details.NotAfter, now),
+		CommonName:      details.CommonName,
+		Fingerprint:     details.Fingerprint,
+		Source:          source,
+	}
+}
+
+// checkExpectations compares the served certificate with the issuer and
+// fingerprint pinned on the target, returning a description of any mismatch
+func checkExpectations(target config.Target, details CertDetails) string {
+	var problems []string
+	if target.ExpectedIssuer != "" && !strings.EqualFold(target.ExpectedIssuer, details.Issuer) {
+		problems = append(problems, fmt.Sprintf("issuer mismatch: expected %s, got %s", target.ExpectedIssuer, details.Issuer))
+	}
+	if target.ExpectedFingerprint != "" {
+		want := strings.ToLower(strings.ReplaceAll(target.ExpectedFingerprint, ":", ""))
+		if !slices.Contains(config.SplitList(want), details.Fingerprint) {
+			problems = append(problems, fmt.Sprintf("fingerprint mismatch: expected %s, got %s", want, details.Fingerprint))
+		}
+	}
+	return strings.Join(problems, "; ")
+}
+
+// ParsePEMCertificates decodes every CERTIFICATE block in data, leaf first
+func ParsePEMCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificates found")
+	}
+	return certs, nil
+}
+
+// ProcessTargets scans every target on its own ports (or defaultPorts) and sends one result per pair.
+// IP targets with DiscoverSNI also get one result per virtual host found behind them.
+func ProcessTargets(ctx context.Context, targets []config.Target, defaultPorts []int, timeout time.Duration, now time.Time, resultsChan chan<- config.DomainValidity, wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	// Create a child logger for this batch if needed, or use default
 	logger := slog.Default()
 
-	for _, domain := range domains {
-		for _, port := range ports {
-			logger.Debug("scanning target", "domain", domain, "port", port)
+	for _, target := range targets {
+		domain := target.Host
+		host := target.Address
+		if host == "" {
+			host = domain
+		}
+		serverName := target.SNI
+		if serverName == "" {
+			serverName = domain
+		}
+
+		// Rows of an IP target carry the IP even when it is the host itself
+		ipAddress := target.Address
+		if ipAddress == "" && net.ParseIP(host) != nil {
+			ipAddress = host
+		}
+
+		discover := target.DiscoverSNI && target.SNI == "" && net.ParseIP(host) != nil
+		var ptrNames []string
+		if discover {
+			ptrNames = lookupPTR(ctx, host, timeout)
+		}
+
+		for _, port := range target.PortsFor(defaultPorts) {
+			logger.Debug("scanning target", "domain", domain, "address", target.Address, "port", port)
 
 			// Create a per-request context with timeout
 			reqCtx, cancel := context.WithTimeout(ctx, timeout)
 
 			// Call updated function
-			details, err := GetSSLValidity(reqCtx, domain, port)
+			details, err := getSSLValidity(reqCtx, host, serverName, port, target.Protocol)
 			cancel() // Clean up context immediately
 
-			result := config.DomainValidity{
-				Domain:        domain,
-				Port:          port,
-				Serial:        details.Serial,
-				TLSVersion:    details.TLSVersion,
-				CipherSuite:   details.CipherSuite,
-				FIPSCompliant: details.FIPSCompliant,
-				ChainStatus:   details.ChainStatus,
-				Issuer:        details.Issuer,
-				SignatureAlgo: details.SignatureAlgo,
-				SANs:          details.SANs,
-				NotBefore:     details.NotBefore,
-				NotAfter:      details.NotAfter,
-				CommonName:    details.CommonName,
+			result := scanResult(target, domain, port, details, err, now)
+			result.IPAddress = ipAddress
+
+			if !discover || err != nil {
+				resultsChan <- result
+				continue
 			}
 
-			if err == nil {
-				result.DaysUntilExpiry = int(details.NotAfter.Sub(now).Hours() / 24)
-			} else {
-				result.Error = err.Error()
-				result.DaysUntilExpiry = 999999
-				logger.Warn("scan failed", "domain", domain, "error", err)
+			// Re-handshake with every name the IP is known by, one row per virtual host.
+			// Names served the same certificate as an earlier row are listed on
+			// that row in the "sni_names" label instead.
+			rows := []config.DomainValidity{result}
+			byFingerprint := map[string]int{details.Fingerprint: 0}
+			for _, name := range discoverServerNames(ptrNames, details.SANs) {
+				logger.Debug("scanning discovered virtual host", "address", host, "server_name", name.Name, "port", port)
+
+				reqCtx, cancel := context.WithTimeout(ctx, timeout)
+				vhost, err := getSSLValidity(reqCtx, host, name.Name, port, target.Protocol)
+				cancel()
+
+				if j, ok := byFingerprint[vhost.Fingerprint]; ok && err == nil {
+					names := config.SplitList(rows[j].Labels["sni_names"])
+					rows[j].Labels = config.WithLabel(rows[j].Labels, "sni_names", strings.Join(append(names, name.Name), ","))
+					continue
+				}
+
+				result := scanResult(target, name.Name, port, vhost, err, now)
+				result.IPAddress = ipAddress
+				result.Labels = config.WithLabel(target.Labels, "sni_source", name.Source)
+				if err == nil {
+					byFingerprint[vhost.Fingerprint] = len(rows)
+				}
+				rows = append(rows, result)
+			}
+			for _, row := range rows {
+				resultsChan <- row
 			}
-			resultsChan <- result
 		}
 	}
 }
+
+// scanResult turns the outcome of one handshake into a result row
+func scanResult(target config.Target, domain string, port int, details CertDetails, err error, now time.Time) config.DomainValidity {
+	result := config.DomainValidity{
+		Domain:        domain,
+		Port:          port,
+		Serial:        details.Serial,
+		TLSVersion:    details.TLSVersion,
+		CipherSuite:   details.CipherSuite,
+		FIPSCompliant: details.FIPSCompliant,
+		ChainStatus:   details.ChainStatus,
+		Issuer:        details.Issuer,
+		SignatureAlgo: details.SignatureAlgo,
+		SANs:          details.SANs,
+		NotBefore:     details.NotBefore,
+		NotAfter:      details.NotAfter,
+		CommonName:    details.CommonName,
+		Labels:        target.Labels,
+		Fingerprint:   details.Fingerprint,
+		Owner:         target.Owner,
+		AlertDays:     target.AlertDays,
+	}
+
+	if err == nil {
+		result.DaysUntilExpiry = DaysUntil(details.NotAfter, now)
+		if mismatch := checkExpectations(target, details); mismatch != "" {
+			result.Error = mismatch
+			slog.Warn("certificate does not match expectations", "domain", domain, "error", mismatch)
+		}
+	} else {
+		result.Error = err.Error()
+		result.DaysUntilExpiry = 999999
+		slog.Warn("scan failed", "domain", domain, "error", err)
+	}
+	return result
+}
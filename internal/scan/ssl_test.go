@@ -2,13 +2,19 @@ package scan
 
 import (
 	"context" // <--- Added
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/hex"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/andre/ssl-cert-test/internal/config"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -55,3 +61,98 @@ func TestGetSSLValidity_TLSVersions(t *testing.T) {
 		})
 	}
 }
+
+func TestProcessTargets_AddressUsesHostAsSNI(t *testing.T) {
+	var gotSNI string
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.TLS = &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			gotSNI = hello.ServerName
+			return nil, nil
+		},
+	}
+	ts.StartTLS()
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	results := make(chan config.DomainValidity, 1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	ProcessTargets(context.Background(), []config.Target{
+		{Host: "backend.example.com", Address: u.Hostname(), Labels: map[string]string{"zone": "example.com"}},
+	}, []int{port}, 5*time.Second, time.Now(), results, &wg)
+	close(results)
+
+	result := <-results
+	assert.Empty(t, result.Error)
+	assert.Equal(t, "backend.example.com", gotSNI)
+	assert.Equal(t, "backend.example.com", result.Domain)
+	assert.Equal(t, u.Hostname(), result.IPAddress)
+	assert.Equal(t, "example.com", result.Labels["zone"])
+}
+
+func TestProcessTargets_TargetPortsOverrideDefaults(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	results := make(chan config.DomainValidity, 2)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	// The default port list would fail to connect; the target's own port wins
+	ProcessTargets(context.Background(), []config.Target{
+		{Host: u.Hostname(), Ports: []int{port}},
+	}, []int{1, 2}, 5*time.Second, time.Now(), results, &wg)
+	close(results)
+
+	var got []config.DomainValidity
+	for r := range results {
+		got = append(got, r)
+	}
+	assert.Len(t, got, 1)
+	assert.Equal(t, port, got[0].Port)
+	assert.Empty(t, got[0].Error)
+}
+
+func TestProcessTargets_SNIAndExpectations(t *testing.T) {
+	var gotSNI []string
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.TLS = &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			gotSNI = append(gotSNI, hello.ServerName)
+			return nil, nil
+		},
+	}
+	ts.StartTLS()
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	port, _ := strconv.Atoi(u.Port())
+	sum := sha256.Sum256(ts.Certificate().Raw)
+	fingerprint := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	results := make(chan config.DomainValidity, 2)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	ProcessTargets(context.Background(), []config.Target{
+		{Host: "pinned", Address: u.Hostname(), SNI: "edge.example.com", Ports: []int{port}, ExpectedFingerprint: "00:11:22," + fingerprint, Owner: "team-edge", AlertDays: 30},
+		{Host: "wrong-ca", Address: u.Hostname(), Ports: []int{port}, ExpectedIssuer: "Let's Encrypt"},
+	}, nil, 5*time.Second, time.Now(), results, &wg)
+	close(results)
+
+	pinned := <-results
+	assert.Equal(t, []string{"edge.example.com", "wrong-ca"}, gotSNI, "the SNI override is sent instead of the host")
+	assert.Equal(t, "pinned", pinned.Domain)
+	assert.Empty(t, pinned.Error, "any fingerprint of the list matches, colon-free and uppercase")
+	assert.Equal(t, strings.ToLower(fingerprint), pinned.Fingerprint)
+	assert.Equal(t, "team-edge", pinned.Owner)
+	assert.Equal(t, 30, pinned.AlertDays)
+
+	wrongCA := <-results
+	assert.Contains(t, wrongCA.Error, "issuer mismatch: expected Let's Encrypt")
+	assert.NotEqual(t, 999999, wrongCA.DaysUntilExpiry, "expiry is still reported for mismatches")
+}
@@ -0,0 +1,82 @@
+package scan
+
+import (
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+)
+
+// startTLS upgrades a plaintext connection so the TLS handshake can start on it
+func startTLS(conn net.Conn, protocol string) error {
+	tp := textproto.NewConn(conn)
+
+	switch strings.ToLower(protocol) {
+	case "smtp":
+		if _, _, err := tp.ReadResponse(220); err != nil {
+			return err
+		}
+		if err := command(tp, 250, "EHLO ssl-cert-checker"); err != nil {
+			return err
+		}
+		return command(tp, 220, "STARTTLS")
+	case "ftp":
+		if _, _, err := tp.ReadResponse(220); err != nil {
+			return err
+		}
+		return command(tp, 234, "AUTH TLS")
+	case "imap":
+		line, err := tp.ReadLine()
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(line, "* OK") && !strings.HasPrefix(line, "* PREAUTH") {
+			return fmt.Errorf("unexpected reply: %s", line)
+		}
+		if err := tp.PrintfLine("a1 STARTTLS"); err != nil {
+			return err
+		}
+		// Servers may send untagged lines (e.g. a CAPABILITY update) first
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(line, "a1 ") {
+				if !strings.HasPrefix(line, "a1 OK") {
+					return fmt.Errorf("unexpected reply: %s", line)
+				}
+				return nil
+			}
+		}
+	case "pop3":
+		if err := expectPrefix(tp, "+OK"); err != nil {
+			return err
+		}
+		if err := tp.PrintfLine("STLS"); err != nil {
+			return err
+		}
+		return expectPrefix(tp, "+OK")
+	default:
+		return fmt.Errorf("unsupported starttls protocol: %s", protocol)
+	}
+}
+
+func command(tp *textproto.Conn, expectCode int, line string) error {
+	if err := tp.PrintfLine("%s", line); err != nil {
+		return err
+	}
+	_, _, err := tp.ReadResponse(expectCode)
+	return err
+}
+
+func expectPrefix(tp *textproto.Conn, prefix string) error {
+	line, err := tp.ReadLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, prefix) {
+		return fmt.Errorf("unexpected reply: %s", line)
+	}
+	return nil
+}
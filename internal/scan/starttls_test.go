@@ -0,0 +1,102 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// serveStartTLS runs a one-shot plaintext server that plays the given script
+// (lines to send, "<" to read one client line) and then starts TLS
+func serveStartTLS(t *testing.T, script []string) int {
+	t.Helper()
+	tmpl, key := createCertTemplate(false, "mail.example.com", nil)
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for _, step := range script {
+			if step == "<" {
+				if _, err := r.ReadString('\n'); err != nil {
+					return
+				}
+				continue
+			}
+			fmt.Fprintf(conn, "%s\r\n", step)
+		}
+		tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{cert}}).Handshake()
+	}()
+
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestGetSSLValidity_StartTLS(t *testing.T) {
+	tests := []struct {
+		protocol string
+		script   []string
+	}{
+		{"smtp", []string{"220 mail ESMTP", "<", "250-mail", "250 STARTTLS", "<", "220 go ahead"}},
+		{"imap", []string{"* OK IMAP ready", "<", "a1 OK begin TLS"}},
+		{"imap", []string{"* PREAUTH IMAP ready", "<", "* CAPABILITY IMAP4rev1 STARTTLS", "a1 OK begin TLS"}},
+		{"pop3", []string{"+OK POP3 ready", "<", "+OK begin TLS"}},
+		{"ftp", []string{"220-welcome", "220 ready", "<", "234 AUTH TLS ok"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.protocol, func(t *testing.T) {
+			port := serveStartTLS(t, tt.script)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			details, err := getSSLValidity(ctx, "127.0.0.1", "mail.example.com", port, tt.protocol)
+
+			assert.NoError(t, err)
+			assert.Equal(t, "mail.example.com", details.CommonName)
+		})
+	}
+}
+
+func TestGetSSLValidity_StartTLSRefused(t *testing.T) {
+	port := serveStartTLS(t, []string{"220 mail ESMTP", "<", "250 mail", "<", "454 TLS not available"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := getSSLValidity(ctx, "127.0.0.1", "mail.example.com", port, "smtp")
+
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "starttls failed"))
+}
+
+func TestGetSSLValidity_StartTLSRefusedIMAP(t *testing.T) {
+	port := serveStartTLS(t, []string{"* OK IMAP ready", "<", "* BYE shutting down", "a1 NO STARTTLS unavailable"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := getSSLValidity(ctx, "127.0.0.1", "mail.example.com", port, "imap")
+
+	assert.ErrorContains(t, err, "a1 NO STARTTLS unavailable")
+}